@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// adaptiveReleases is the number of release times to keep to work out the cadence of a Service.
+	adaptiveReleases = 10
+	// adaptiveQueriesPerRelease is how many times to query in the usual gap between releases (with adaptive).
+	adaptiveQueriesPerRelease = 24
+)
+
+// getInterval returns the time to wait between queries of the Service.
+//
+// With Adaptive, that's a fraction of the usual gap between its releases (within MinInterval and MaxInterval),
+// otherwise it's the Interval.
+func (s *Service) getInterval() time.Duration {
+	interval, _ := time.ParseDuration(s.Interval)
+	if s.Adaptive != "y" {
+		return interval
+	}
+
+	if cadence := s.status.getCadence(); cadence != 0 {
+		interval = cadence / adaptiveQueriesPerRelease
+	}
+	minInterval, _ := time.ParseDuration(s.MinInterval)
+	maxInterval, _ := time.ParseDuration(s.MaxInterval)
+	if interval < minInterval {
+		interval = minInterval
+	}
+	if maxInterval != 0 && interval > maxInterval {
+		interval = maxInterval
+	}
+	return interval
+}
+
+// backoff returns interval doubled for each query in a row that failed (up to MaxInterval, or interval if that's longer).
+//
+// Only an Adaptive Service backs off, others keep to their fixed Interval.
+func (s *Service) backoff(interval time.Duration) time.Duration {
+	failures := s.status.getFailures()
+	if failures == 0 || s.Adaptive != "y" {
+		return interval
+	}
+
+	limit, _ := time.ParseDuration(s.MaxInterval)
+	if limit < interval {
+		limit = interval
+	}
+	delay := interval
+	for i := uint(0); i < failures && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+
+	msg := fmt.Sprintf("%s, %d queries in a row failed, backing off for %s", s.ID, failures, delay)
+	jLog.Verbose(msg, true)
+	return delay
+}
+
+// getCadence returns the median gap between the releases found (0 if there aren't enough to tell).
+func (s *status) getCadence() time.Duration {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if len(s.releases) < 2 {
+		return 0
+	}
+
+	releases := make([]time.Time, len(s.releases))
+	copy(releases, s.releases)
+	sort.Slice(releases, func(i, j int) bool { return releases[i].Before(releases[j]) })
+	gaps := make([]time.Duration, 0, len(releases)-1)
+	for i := 1; i < len(releases); i++ {
+		gaps = append(gaps, releases[i].Sub(releases[i-1]))
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+	return gaps[len(gaps)/2]
+}
+
+// addRelease will record that a release was made at released (keeping the last adaptiveReleases).
+func (s *status) addRelease(released time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, release := range s.releases {
+		if release.Equal(released) {
+			return
+		}
+	}
+	s.releases = append(s.releases, released.UTC())
+	if len(s.releases) > adaptiveReleases {
+		s.releases = s.releases[len(s.releases)-adaptiveReleases:]
+	}
+}
+
+// setReleases sets the release times (e.g. those kept in the State from before a restart).
+func (s *status) setReleases(releases []time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.releases = append([]time.Time{}, releases...)
+}
+
+// getFailures returns the number of queries in a row that failed.
+func (s *status) getFailures() uint {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.failures
+}
+
+// setFailed will count a failed query (or reset the count on a successful one).
+func (s *status) setFailed(failed bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if failed {
+		s.failures++
+	} else {
+		s.failures = 0
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServiceGetInterval(t *testing.T) {
+	svc := Service{Interval: "10m", Adaptive: "y", MinInterval: "5m", MaxInterval: "24h", status: newStatus()}
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Not enough releases to tell, so the interval.
+	svc.status.addRelease(start)
+	if got := svc.getInterval(); got != 10*time.Minute {
+		t.Fatalf(`getInterval() with 1 release = %s, want match for 10m`, got)
+	}
+
+	// Releases every 2 days (and an outlier), so query every 2h.
+	for _, days := range []int{2, 4, 6, 30} {
+		svc.status.addRelease(start.AddDate(0, 0, days))
+	}
+	if got := svc.getInterval(); got != 2*time.Hour {
+		t.Fatalf(`getInterval() with releases every 2 days = %s, want match for 2h`, got)
+	}
+
+	// Within the bounds.
+	svc.MaxInterval = "1h"
+	if got := svc.getInterval(); got != time.Hour {
+		t.Fatalf(`getInterval() with max_interval 1h = %s, want match for 1h`, got)
+	}
+	svc.MinInterval = "3h"
+	svc.MaxInterval = "24h"
+	if got := svc.getInterval(); got != 3*time.Hour {
+		t.Fatalf(`getInterval() with min_interval 3h = %s, want match for 3h`, got)
+	}
+
+	// Not adaptive.
+	svc.Adaptive = "n"
+	if got := svc.getInterval(); got != 10*time.Minute {
+		t.Fatalf(`getInterval() without adaptive = %s, want match for 10m`, got)
+	}
+}
+
+func TestServiceBackoff(t *testing.T) {
+	failing := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("1.2.3"))
+	}))
+	defer server.Close()
+	svc := Service{ID: "Service", Type: "url", URL: server.URL, Interval: "10m", Adaptive: "y", MinInterval: "5m", MaxInterval: "1h", ProgressiveVersioning: "n", Confirmations: 1, status: newStatus()}
+	now := time.Now()
+
+	for _, want := range []time.Duration{20 * time.Minute, 40 * time.Minute, time.Hour, time.Hour} {
+		if got := svc.query(0, "Monitor"); got != queryFailed {
+			t.Fatalf(`query() of a 503 = %d, want match for %d`, got, queryFailed)
+		}
+		if got := svc.nextQuery(now).Sub(now); got != want {
+			t.Fatalf(`nextQuery() after %d failures = %s, want match for %s`, svc.status.getFailures(), got, want)
+		}
+	}
+
+	// A successful query resets the back off.
+	failing = false
+	if got := svc.query(0, "Monitor"); got != queryNoChange {
+		t.Fatalf(`query() = %d, want match for %d`, got, queryNoChange)
+	}
+	if got := svc.nextQuery(now).Sub(now); got != 10*time.Minute {
+		t.Fatalf(`nextQuery() after a success = %s, want match for 10m`, got)
+	}
+
+	// Without adaptive, the interval is fixed.
+	failing = true
+	svc.Adaptive = "n"
+	svc.query(0, "Monitor")
+	if got := svc.nextQuery(now).Sub(now); got != 10*time.Minute {
+		t.Fatalf(`nextQuery() after a failure without adaptive = %s, want match for 10m`, got)
+	}
+}
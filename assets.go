@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Assets are the files a release must have (and be verified with) before it's notified (type:github).
+type Assets struct {
+	Require   []string `yaml:"require"`   // Globs of the assets that must exist on the release, e.g. "app-*-linux-amd64.tar.gz". '${version}' is replaced with the version.
+	Verify    string   `yaml:"verify"`    // default - false = Don't download the required assets to verify them against their SHA256 checksums.
+	Checksums string   `yaml:"checksums"` // default - "*SHA256SUMS*" = Glob of the checksum file on the release (an "ASSET.sha256" is used first, and neither is used with a signature).
+}
+
+// setDefaults sets undefined variables to their default.
+func (a *Assets) setDefaults(defaults Assets) {
+	a.Verify = valueOrValueString(a.Verify, defaults.Verify)
+	a.Verify = stringBool(a.Verify, "", "", false)
+	a.Checksums = valueOrValueString(a.Checksums, defaults.Checksums)
+}
+
+// checkValues will check that the globs are valid (target is where in the config they are).
+func (a *Assets) checkValues(target string) {
+	for index, glob := range append(a.Require, a.Checksums) {
+		if _, err := path.Match(glob, ""); err != nil {
+			name := fmt.Sprintf("%s.assets.require[%d]", target, index)
+			if index == len(a.Require) {
+				name = target + ".assets.checksums"
+			}
+			msg := fmt.Sprintf("%s (%s) is an invalid glob\n%s", name, glob, err)
+			jLog.Fatal(msg, true)
+		}
+	}
+}
+
+// print will print the Assets.
+func (a *Assets) print(prefix string) {
+	if len(a.Require) == 0 {
+		return
+	}
+	fmt.Printf("%sassets:\n", prefix)
+	fmt.Printf("%s  require: ['%s']\n", prefix, strings.Join(a.Require, "', '"))
+	fmt.Printf("%s  verify: %s\n", prefix, a.Verify)
+	if a.Verify == "y" {
+		fmt.Printf("%s  checksums: '%s'\n", prefix, a.Checksums)
+	}
+}
+
+// checkAssets returns an error describing why release isn't complete (and verified) yet,
+// or nil when it has all of the required assets.
+//
+// signed is the contents of the signed file from checkSignature (nil if it isn't signed).
+func (s *Service) checkAssets(release GitHubRelease, version string, signed []byte) error {
+	if len(s.Assets.Require) == 0 {
+		return nil
+	}
+
+	var required []GitHubAsset
+	for _, glob := range s.Assets.Require {
+		glob = strings.ReplaceAll(glob, "${version}", version)
+		found := false
+		for _, asset := range release.Assets {
+			if matched, _ := path.Match(glob, asset.Name); matched {
+				required = append(required, asset)
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("no asset matching '%s'", glob)
+		}
+	}
+
+	if s.Assets.Verify != "y" {
+		return nil
+	}
+	checksums := map[string]string{}
+	if s.Signature.Type != "" {
+		// Only the checksums covered by the signature can be trusted.
+		// An unsigned release can't be verified, but WebHooks/Execs aren't sent for it.
+		if signed == nil {
+			return nil
+		}
+		checksums = parseChecksums(string(signed))
+	}
+	for _, asset := range required {
+		checksum, err := s.getChecksum(release, asset, checksums)
+		if err != nil {
+			return err
+		}
+		hash, err := s.hashAsset(asset)
+		if err != nil {
+			return fmt.Errorf("failed to download '%s'\n%s", asset.Name, err)
+		}
+		if hash != checksum {
+			return fmt.Errorf("the SHA256 of '%s' is %s, want %s", asset.Name, hash, checksum)
+		}
+	}
+	return nil
+}
+
+// getChecksum returns the SHA256 checksum of asset given on release (by 'ASSET.sha256', or the checksums file).
+//
+// checksums caches the contents of the checksums file (or holds those of the signed file with a Signature).
+func (s *Service) getChecksum(release GitHubRelease, asset GitHubAsset, checksums map[string]string) (string, error) {
+	if s.Signature.Type != "" {
+		if checksum := checksums[asset.Name]; checksum != "" {
+			return checksum, nil
+		}
+		return "", fmt.Errorf("no checksum found for '%s' in the signed '%s'", asset.Name, s.Signature.File)
+	}
+
+	for _, file := range release.Assets {
+		if file.Name == asset.Name+".sha256" {
+			body, err := s.get(file.BrowserDownloadURL, "")
+			if err != nil {
+				return "", fmt.Errorf("failed to download '%s'\n%s", file.Name, err)
+			}
+			sums := parseChecksums(string(body))
+			if checksum := valueOrValueString(sums[asset.Name], sums[""]); checksum != "" {
+				return checksum, nil
+			}
+			return "", fmt.Errorf("no checksum found in '%s'", file.Name)
+		}
+	}
+
+	if len(checksums) == 0 {
+		for _, file := range release.Assets {
+			if matched, _ := path.Match(s.Assets.Checksums, file.Name); !matched || isSignatureAsset(file.Name) {
+				continue
+			}
+			body, err := s.get(file.BrowserDownloadURL, "")
+			if err != nil {
+				return "", fmt.Errorf("failed to download '%s'\n%s", file.Name, err)
+			}
+			for name, checksum := range parseChecksums(string(body)) {
+				checksums[name] = checksum
+			}
+		}
+	}
+	if checksum := checksums[asset.Name]; checksum != "" {
+		return checksum, nil
+	}
+	return "", fmt.Errorf("no checksum found for '%s'", asset.Name)
+}
+
+// hashAsset returns the SHA256 (hex) of the download of asset.
+func (s *Service) hashAsset(asset GitHubAsset) (string, error) {
+	reader, err := s.open(asset.BrowserDownloadURL, "", 10*time.Minute)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// parseChecksums returns the checksums of a `sha256sum` output as a map of file name to checksum.
+//
+// A lone checksum (e.g. the contents of 'ASSET.sha256') has the name "".
+func parseChecksums(text string) map[string]string {
+	checksums := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+			continue
+		}
+		name := ""
+		if len(fields) > 1 {
+			// '*' marks binary mode.
+			name = path.Base(strings.TrimPrefix(fields[1], "*"))
+		}
+		checksums[name] = strings.ToLower(fields[0])
+	}
+	return checksums
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseChecksums(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	got := parseChecksums(fmt.Sprintf("%s  app-linux.tar.gz\n%s *./app-darwin.tar.gz\nnot a checksum\n", sum, strings.ToUpper(sum)))
+	if len(got) != 2 || got["app-linux.tar.gz"] != sum || got["app-darwin.tar.gz"] != sum {
+		t.Fatalf(`parseChecksums() = %v, want match for app-linux.tar.gz and app-darwin.tar.gz`, got)
+	}
+
+	got = parseChecksums(sum + "\n")
+	if got[""] != sum {
+		t.Fatalf(`parseChecksums() = %v, want match for a lone checksum`, got)
+	}
+}
+
+func TestServiceGitHubOnly(t *testing.T) {
+	// Without a type, a URL Service can't have assets or a signature.
+	svc := Service{ID: "Service", URL: "https://example.com/version", Assets: Assets{Require: []string{"app-*"}}}
+	svc.setDefaults(Defaults{})
+	if got := svc.gitHubOnly(); len(got) != 1 || got[0] != "assets" {
+		t.Fatalf(`gitHubOnly() of type %s = %v, want match for [assets]`, svc.Type, got)
+	}
+	svc.Signature = Signature{Type: "gpg", Key: "key.asc"}
+	if got := strings.Join(svc.gitHubOnly(), ","); got != "assets,signature" {
+		t.Fatalf(`gitHubOnly() of type %s = %s, want match for assets,signature`, svc.Type, got)
+	}
+
+	svc.Type = "github"
+	if got := svc.gitHubOnly(); len(got) != 0 {
+		t.Fatalf(`gitHubOnly() of type github = %v, want match for []`, got)
+	}
+}
+
+func TestCheckAssets(t *testing.T) {
+	files := map[string]string{
+		"app-1.2.3-linux-amd64.tar.gz":  "linux",
+		"app-1.2.3-darwin-arm64.tar.gz": "darwin",
+	}
+	sums := ""
+	for name, data := range files {
+		hash := sha256.Sum256([]byte(data))
+		sums += fmt.Sprintf("%s  %s\n", hex.EncodeToString(hash[:]), name)
+	}
+	files["SHA256SUMS"] = sums
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, exists := files[strings.TrimPrefix(r.URL.Path, "/")]
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, data)
+	}))
+	defer server.Close()
+
+	release := GitHubRelease{}
+	for _, name := range []string{"app-1.2.3-linux-amd64.tar.gz", "SHA256SUMS"} {
+		release.Assets = append(release.Assets, GitHubAsset{Name: name, BrowserDownloadURL: server.URL + "/" + name})
+	}
+	svc := Service{Assets: Assets{Require: []string{"app-${version}-linux-*", "app-${version}-darwin-*"}, Verify: "y", Checksums: "*SHA256SUMS*"}}
+
+	// Missing the darwin asset.
+	if err := svc.checkAssets(release, "1.2.3", nil); err == nil || !strings.Contains(err.Error(), "darwin") {
+		t.Fatalf(`checkAssets() = %v, want match for the darwin asset missing`, err)
+	}
+
+	// Complete and verified.
+	release.Assets = append(release.Assets, GitHubAsset{Name: "app-1.2.3-darwin-arm64.tar.gz", BrowserDownloadURL: server.URL + "/app-1.2.3-darwin-arm64.tar.gz"})
+	if err := svc.checkAssets(release, "1.2.3", nil); err != nil {
+		t.Fatalf(`checkAssets() = %v, want match for <nil>`, err)
+	}
+
+	// Checksum mismatch.
+	files["app-1.2.3-darwin-arm64.tar.gz"] = "tampered"
+	if err := svc.checkAssets(release, "1.2.3", nil); err == nil || !strings.Contains(err.Error(), "SHA256") {
+		t.Fatalf(`checkAssets() = %v, want match for a SHA256 mismatch`, err)
+	}
+
+	// With a signature, only the signed checksums are used (not an unsigned 'ASSET.sha256').
+	hash := sha256.Sum256([]byte("tampered"))
+	files["app-1.2.3-darwin-arm64.tar.gz.sha256"] = hex.EncodeToString(hash[:])
+	release.Assets = append(release.Assets, GitHubAsset{Name: "app-1.2.3-darwin-arm64.tar.gz.sha256", BrowserDownloadURL: server.URL + "/app-1.2.3-darwin-arm64.tar.gz.sha256"})
+	svc.Assets.Require = []string{"app-${version}-darwin-*.tar.gz"}
+	if err := svc.checkAssets(release, "1.2.3", nil); err != nil {
+		t.Fatalf(`checkAssets() = %v, want match for <nil> from the unsigned 'ASSET.sha256'`, err)
+	}
+	svc.Signature = Signature{Type: "gpg", File: "SHA256SUMS"}
+	if err := svc.checkAssets(release, "1.2.3", []byte(sums)); err == nil || !strings.Contains(err.Error(), "SHA256") {
+		t.Fatalf(`checkAssets() = %v, want match for a SHA256 mismatch against the signed checksums`, err)
+	}
+}
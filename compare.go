@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CompareSummary is what changed between the previous and new versions of a Service.
+type CompareSummary struct {
+	URL          string   `json:"html_url"`      // "https://github.com/owner/repo/compare/v1.2.2...v1.2.3"
+	CommitCount  int      `json:"total_commits"` // 12
+	Authors      []string `json:"authors"`       // ["octocat", ...]
+	PullRequests []string `json:"pull_requests"` // ["Fix the thing (#123)", ...] (capped at Service.CompareMaxPRs)
+	MorePRs      int      `json:"more_prs"`      // Number of pull requests over the cap.
+}
+
+// gitHubCompare is the subset of a GitHub API comparison that is used.
+type gitHubCompare struct {
+	HTMLURL      string `json:"html_url"`
+	TotalCommits int    `json:"total_commits"`
+	Commits      []struct {
+		Author *struct {
+			Login string `json:"login"`
+		} `json:"author"`
+		Commit struct {
+			Message string `json:"message"`
+			Author  struct {
+				Name string `json:"name"`
+			} `json:"author"`
+		} `json:"commit"`
+	} `json:"commits"`
+}
+
+var (
+	compareMergeCommit  = regexp.MustCompile(`^Merge pull request (#[0-9]+) from \S+\s*\n\s*\n(.+)`)
+	compareSquashCommit = regexp.MustCompile(`^(.+ \(#[0-9]+\))\s*$`)
+)
+
+// addCompare will set the CompareSummary of event to what changed between its previous and new
+// versions (when Service.Compare is enabled for this type:github Service).
+func (s *Service) addCompare(event *ReleaseEvent) {
+	if s.Compare != "y" || s.Type != "github" || event.PreviousTag == "" {
+		return
+	}
+
+	compareURL := fmt.Sprintf("https://api.github.com/repos/%s/compare/%s...%s", s.getRepository(), event.PreviousTag, event.getTag())
+	body, err := s.get(compareURL, "application/vnd.github.v3+json")
+	if err != nil {
+		msg := fmt.Sprintf("%s (%s), failed to compare %s...%s\n%s", s.ID, event.MonitorID, event.PreviousTag, event.getTag(), err)
+		jLog.Warn(msg, true)
+		return
+	}
+
+	var compare gitHubCompare
+	if err := json.Unmarshal(body, &compare); err != nil {
+		msg := fmt.Sprintf("%s (%s), failed to parse the comparison of %s...%s\n%s", s.ID, event.MonitorID, event.PreviousTag, event.getTag(), err)
+		jLog.Warn(msg, true)
+		return
+	}
+	event.Compare = newCompareSummary(compare, s.CompareMaxPRs)
+}
+
+// newCompareSummary returns the CompareSummary of a GitHub comparison, with up to maxPRs pull request titles.
+func newCompareSummary(compare gitHubCompare, maxPRs uint) *CompareSummary {
+	summary := &CompareSummary{
+		URL:          compare.HTMLURL,
+		CommitCount:  compare.TotalCommits,
+		Authors:      []string{},
+		PullRequests: []string{},
+	}
+
+	seenAuthors := map[string]bool{}
+	for _, commit := range compare.Commits {
+		// Author (GitHub login if there's one, otherwise the git name).
+		author := commit.Commit.Author.Name
+		if commit.Author != nil && commit.Author.Login != "" {
+			author = commit.Author.Login
+		}
+		if author != "" && !seenAuthors[author] {
+			seenAuthors[author] = true
+			summary.Authors = append(summary.Authors, author)
+		}
+
+		// Merged pull request (merge or squash commit).
+		title := ""
+		if match := compareMergeCommit.FindStringSubmatch(commit.Commit.Message); match != nil {
+			title = fmt.Sprintf("%s (%s)", strings.TrimSpace(match[2]), match[1])
+		} else if match := compareSquashCommit.FindStringSubmatch(strings.SplitN(commit.Commit.Message, "\n", 2)[0]); match != nil {
+			title = match[1]
+		}
+		if title == "" {
+			continue
+		}
+		if uint(len(summary.PullRequests)) < maxPRs {
+			summary.PullRequests = append(summary.PullRequests, title)
+		} else {
+			summary.MorePRs++
+		}
+	}
+	return summary
+}
+
+// format returns the CompareSummary as lines of text for the default messages.
+//
+// e.g. "12 commits by octocat, hubot - https://github.com/owner/repo/compare/v1.2.2...v1.2.3"
+// followed by "- Fix the thing (#123)" for each pull request.
+func (c *CompareSummary) format() string {
+	commits := "commits"
+	if c.CommitCount == 1 {
+		commits = "commit"
+	}
+	line := fmt.Sprintf("%d %s", c.CommitCount, commits)
+	if len(c.Authors) != 0 {
+		line += " by " + strings.Join(c.Authors, ", ")
+	}
+	if c.URL != "" {
+		line += " - " + c.URL
+	}
+
+	lines := []string{line}
+	for _, title := range c.PullRequests {
+		lines = append(lines, "- "+title)
+	}
+	if c.MorePRs != 0 {
+		lines = append(lines, fmt.Sprintf("- ...and %d more", c.MorePRs))
+	}
+	return strings.Join(lines, "\n")
+}
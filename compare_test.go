@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewCompareSummary(t *testing.T) {
+	data := `{
+  "html_url": "https://github.com/owner/repo/compare/v1.2.2...v1.2.3",
+  "total_commits": 4,
+  "commits": [
+    {"author": {"login": "alice"}, "commit": {"message": "Merge pull request #1 from alice/fix\n\nFix the thing", "author": {"name": "Alice"}}},
+    {"author": null, "commit": {"message": "Add the other thing (#2)\n\n* wip", "author": {"name": "Bob"}}},
+    {"author": {"login": "alice"}, "commit": {"message": "Tidy up", "author": {"name": "Alice"}}},
+    {"author": {"login": "carol"}, "commit": {"message": "Speed it up (#3)", "author": {"name": "Carol"}}}
+  ]
+}`
+	var compare gitHubCompare
+	if err := json.Unmarshal([]byte(data), &compare); err != nil {
+		t.Fatalf(`json.Unmarshal() = %v, want match for <nil>`, err)
+	}
+
+	got := newCompareSummary(compare, 2)
+	if got.CommitCount != 4 || got.URL != "https://github.com/owner/repo/compare/v1.2.2...v1.2.3" {
+		t.Fatalf(`summary = %+v, want match for 4 commits`, got)
+	}
+	if authors := strings.Join(got.Authors, ","); authors != "alice,Bob,carol" {
+		t.Fatalf(`summary.Authors = %s, want match for alice,Bob,carol`, authors)
+	}
+	// Capped at 2 pull requests.
+	if prs := strings.Join(got.PullRequests, ","); prs != "Fix the thing (#1),Add the other thing (#2)" || got.MorePRs != 1 {
+		t.Fatalf(`summary.PullRequests = %s (+%d), want match for Fix the thing (#1),Add the other thing (#2) (+1)`, prs, got.MorePRs)
+	}
+}
+
+func TestCompareInDefaultMessages(t *testing.T) {
+	event := &ReleaseEvent{ServiceID: "owner/repo", Version: "1.2.3", Compare: &CompareSummary{
+		URL:          "https://github.com/owner/repo/compare/v1.2.2...v1.2.3",
+		CommitCount:  4,
+		Authors:      []string{"alice", "bob"},
+		PullRequests: []string{"Fix the thing (#1)"},
+		MorePRs:      1,
+	}}
+	want := "4 commits by alice, bob - https://github.com/owner/repo/compare/v1.2.2...v1.2.3\n- Fix the thing (#1)\n- ...and 1 more"
+
+	slack := Slack{Message: "${service_id} - ${version} released"}
+	if payload, err := slack.getPayload(event, ""); err != nil || !strings.HasSuffix(payload.Text, "released\n"+want) {
+		t.Fatalf(`Slack getPayload().Text = %q, %v - want match for the compare summary %q`, payload.Text, err, want)
+	}
+	gotify := Gotify{Message: "${service_id} - ${version} released"}
+	if payload, err := gotify.getPayload(event, "", "", Gotify{}); err != nil || !strings.HasSuffix(payload.Message, "released\n"+want) {
+		t.Fatalf(`Gotify getPayload().Message = %q, %v - want match for the compare summary %q`, payload.Message, err, want)
+	}
+
+	// Not when the message has its own.
+	slack.Message = "${service_id} {{ .compare.CommitCount }} commits"
+	if payload, _ := slack.getPayload(event, ""); payload.Text != "owner/repo 4 commits" {
+		t.Fatalf(`Slack getPayload().Text = %q, want match for "owner/repo 4 commits"`, payload.Text)
+	}
+}
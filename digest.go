@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Digest is when to batch the releases sent to a messenger into one summary message.
+type Digest struct {
+	Schedule    string        `yaml:"schedule"`     // "hourly"/"daily"/"weekly" or a cron expression of when to send the digest.
+	QuietPeriod string        `yaml:"quiet_period"` // Send the digest once no release has been added to it for this long (e.g. "30m").
+	Bypass      []string      `yaml:"bypass"`       // default - [security] = Severities of releases to send straight away (not in the digest).
+	schedule    *cronSchedule ``                    // The parsed Schedule.
+}
+
+// DigestEntry is a release waiting to be sent in a digest.
+type DigestEntry struct {
+	MonitorID       string    `json:"monitor_id"`       // "SERVICE_NAME"
+	ServiceID       string    `json:"service_id"`       // "owner/repo"
+	PreviousVersion string    `json:"previous_version"` // "1.2.3"
+	Version         string    `json:"version"`          // "1.2.4"
+	Severity        string    `json:"severity"`         // "normal"
+	Added           time.Time `json:"added"`            // When it was added to the digest.
+}
+
+// digestSchedules are the names that can be used for Digest.Schedule.
+var digestSchedules = map[string]string{
+	"hourly": "@hourly",
+	"daily":  "@daily",
+	"weekly": "@weekly",
+}
+
+// setDefaults sets undefined variables to their default.
+func (d *Digest) setDefaults(defaults *Digest) {
+	if defaults != nil {
+		d.Schedule = valueOrValueString(d.Schedule, defaults.Schedule)
+		d.QuietPeriod = valueOrValueString(d.QuietPeriod, defaults.QuietPeriod)
+		if d.Bypass == nil {
+			d.Bypass = defaults.Bypass
+		}
+	}
+	if d.Bypass == nil {
+		d.Bypass = []string{severitySecurity}
+	}
+}
+
+// checkValues will check that the variables are valid (parsing the Schedule in location).
+func (d *Digest) checkValues(target string, location *time.Location) {
+	if d.Schedule == "" && d.QuietPeriod == "" {
+		msg := fmt.Sprintf("%s.digest needs a schedule and/or a quiet_period", target)
+		jLog.Fatal(msg, true)
+	}
+
+	// Schedule
+	d.schedule = nil
+	if d.Schedule != "" {
+		d.Schedule = strings.ToLower(d.Schedule)
+		schedule, err := parseCron(valueOrValueString(digestSchedules[d.Schedule], d.Schedule), location)
+		if err != nil {
+			msg := fmt.Sprintf("%s.digest.schedule (%s) is invalid (Use 'hourly', 'daily', 'weekly' or a cron expression)\n%s", target, d.Schedule, err)
+			jLog.Fatal(msg, true)
+		}
+		d.schedule = schedule
+	}
+
+	// QuietPeriod
+	if d.QuietPeriod != "" {
+		if _, err := time.ParseDuration(d.QuietPeriod); err != nil {
+			msg := fmt.Sprintf("%s.digest.quiet_period (%s) is invalid (Use 'AhBmCs' duration format)", target, d.QuietPeriod)
+			jLog.Fatal(msg, true)
+		}
+	}
+
+	// Bypass
+	for index := range d.Bypass {
+		d.Bypass[index] = strings.ToLower(d.Bypass[index])
+		checkSeverity(d.Bypass[index], fmt.Sprintf("%s.digest.bypass[%d]", target, index))
+	}
+}
+
+// print will print the Digest.
+func (d *Digest) print(prefix string) {
+	fmt.Printf("%sdigest:\n", prefix)
+	if d.Schedule != "" {
+		fmt.Printf("%s  schedule: '%s'\n", prefix, d.Schedule)
+	}
+	if d.QuietPeriod != "" {
+		fmt.Printf("%s  quiet_period: %s\n", prefix, d.QuietPeriod)
+	}
+	fmt.Printf("%s  bypass: [%s]\n", prefix, strings.Join(d.Bypass, ", "))
+}
+
+// bypasses returns whether releases of any of severities skip the Digest.
+func (d *Digest) bypasses(severities []string) bool {
+	return hasSeverity(d.Bypass, severities)
+}
+
+// due returns when the digest of entries (oldest first) should be sent.
+func (d *Digest) due(entries []DigestEntry) time.Time {
+	var due time.Time
+	if d.schedule != nil {
+		due = d.schedule.next(entries[0].Added)
+	}
+	if quietPeriod, _ := time.ParseDuration(d.QuietPeriod); quietPeriod > 0 {
+		if quiet := entries[len(entries)-1].Added.Add(quietPeriod); due.IsZero() || quiet.Before(due) {
+			due = quiet
+		}
+	}
+	return due
+}
+
+// digests returns whether the release of event to notifier should wait for its digest.
+func digests(notifier Notifier, event *ReleaseEvent) bool {
+	digest := notifier.getOptions().Digest
+	return digest != nil && notifierTypes[notifier.getOptions().Kind].messenger && !digest.bypasses(event.Severities)
+}
+
+// addDigest will add the release of event to the digest of notifier.
+func (m *Monitor) addDigest(notifier Notifier, event *ReleaseEvent) {
+	msg := fmt.Sprintf("%s (%s), Adding %s to the digest of %s", event.ServiceID, m.ID, event.Version, notifier.getTarget())
+	jLog.Verbose(msg, true)
+	state.addDigest(getChannelKey(notifier), DigestEntry{
+		MonitorID:       m.ID,
+		ServiceID:       event.ServiceID,
+		PreviousVersion: event.PreviousVersion,
+		Version:         event.Version,
+		Severity:        event.Severity,
+		Added:           time.Now().UTC(),
+	})
+}
+
+// formatDigest returns the title and message of the digest of entries (oldest first).
+//
+// The releases are grouped by Monitor, with each Service listed once (from the version before its
+// first release in the digest to its latest).
+func formatDigest(entries []DigestEntry) (string, string) {
+	var (
+		monitorIDs []string
+		services   = map[string][]*DigestEntry{}
+	)
+	for index := range entries {
+		entry := entries[index]
+		if _, exists := services[entry.MonitorID]; !exists {
+			monitorIDs = append(monitorIDs, entry.MonitorID)
+		}
+
+		var existing *DigestEntry
+		for _, service := range services[entry.MonitorID] {
+			if service.ServiceID == entry.ServiceID {
+				existing = service
+			}
+		}
+		if existing == nil {
+			services[entry.MonitorID] = append(services[entry.MonitorID], &entry)
+			continue
+		}
+		existing.Version = entry.Version
+		if severityRank(entry.Severity) < severityRank(existing.Severity) {
+			existing.Severity = entry.Severity
+		}
+	}
+
+	var lines []string
+	for _, monitorID := range monitorIDs {
+		lines = append(lines, monitorID+":")
+		for _, service := range services[monitorID] {
+			line := fmt.Sprintf("- %s %s → %s", service.ServiceID, valueOrValueString(service.PreviousVersion, "?"), service.Version)
+			if service.Severity != "" && service.Severity != severityNormal {
+				line += fmt.Sprintf(" (%s)", service.Severity)
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	releases := "releases"
+	if len(entries) == 1 {
+		releases = "release"
+	}
+	title := fmt.Sprintf("Release digest - %d %s", len(entries), releases)
+	return title, strings.Join(lines, "\n")
+}
+
+// severityRank returns the rank of severity (0 = the most severe).
+func severityRank(severity string) int {
+	for rank, valid := range severities {
+		if severity == valid {
+			return rank
+		}
+	}
+	return len(severities)
+}
+
+// sendDigests will queue the digests of the messengers of monitors that are due at now.
+func sendDigests(monitors MonitorSlice, defaults Defaults, now time.Time) {
+	sent := map[string]bool{}
+	for monitorIndex := range monitors {
+		monitor := &monitors[monitorIndex]
+		for _, notifier := range monitor.notifiers {
+			digest := notifier.getOptions().Digest
+			key := getChannelKey(notifier)
+			if digest == nil || sent[key] {
+				continue
+			}
+			// Only the first messenger of a channel sends its digest.
+			sent[key] = true
+
+			entries := state.getDigest(key)
+			if len(entries) == 0 || digest.due(entries).After(now) {
+				continue
+			}
+			entries = state.takeDigest(key)
+			if len(entries) == 0 {
+				continue
+			}
+
+			title, message := formatDigest(entries)
+			msg := fmt.Sprintf("Sending the digest of %d releases to %s", len(entries), notifier.getTarget())
+			jLog.Info(msg, true)
+			event := &ReleaseEvent{MonitorID: monitor.ID, ServiceID: "digest", digest: entries}
+			monitor.queue(notifier, event, title, message, defaults)
+		}
+	}
+}
+
+// runDigests will send the digests of the messengers of monitors when they're due (forever).
+func runDigests(monitors MonitorSlice, defaults Defaults) {
+	for {
+		sendDigests(monitors, defaults, time.Now())
+		time.Sleep(time.Minute)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDigest(t *testing.T) {
+	entries := []DigestEntry{
+		{MonitorID: "A", ServiceID: "owner/repo", PreviousVersion: "1.2.3", Version: "1.2.4", Severity: severityNormal},
+		{MonitorID: "B", ServiceID: "other/repo", PreviousVersion: "0.9.0", Version: "1.0.0", Severity: severityBreaking},
+		{MonitorID: "A", ServiceID: "owner/repo", PreviousVersion: "1.2.4", Version: "1.2.5", Severity: severitySecurity},
+		{MonitorID: "A", ServiceID: "owner/lib", Version: "2.0.0", Severity: severityNormal},
+	}
+
+	title, message := formatDigest(entries)
+	if want := "Release digest - 4 releases"; title != want {
+		t.Fatalf(`formatDigest() title = %q, want match for %q`, title, want)
+	}
+	want := "A:\n- owner/repo 1.2.3 → 1.2.5 (security)\n- owner/lib ? → 2.0.0\nB:\n- other/repo 0.9.0 → 1.0.0 (breaking)"
+	if message != want {
+		t.Fatalf(`formatDigest() message = %q, want match for %q`, message, want)
+	}
+}
+
+func TestDigestDue(t *testing.T) {
+	start := time.Date(2022, 6, 6, 9, 30, 0, 0, time.UTC)
+	entries := []DigestEntry{{Added: start}, {Added: start.Add(20 * time.Minute)}}
+
+	digest := Digest{Schedule: "hourly"}
+	digest.checkValues("test", time.UTC)
+	if got, want := digest.due(entries), start.Add(30*time.Minute); !got.Equal(want) {
+		t.Fatalf(`due() of hourly = %s, want match for %s`, got, want)
+	}
+
+	// A quiet period from the last release.
+	digest = Digest{QuietPeriod: "15m"}
+	digest.checkValues("test", time.UTC)
+	if got, want := digest.due(entries), start.Add(35*time.Minute); !got.Equal(want) {
+		t.Fatalf(`due() of quiet_period 15m = %s, want match for %s`, got, want)
+	}
+
+	// Whichever is first.
+	digest = Digest{Schedule: "daily", QuietPeriod: "1h"}
+	digest.checkValues("test", time.UTC)
+	if got, want := digest.due(entries), start.Add(80*time.Minute); !got.Equal(want) {
+		t.Fatalf(`due() of daily or quiet_period 1h = %s, want match for %s`, got, want)
+	}
+}
+
+func TestDigests(t *testing.T) {
+	digest := &Digest{Schedule: "daily"}
+	digest.setDefaults(nil)
+	event := &ReleaseEvent{Severities: []string{severityNormal}}
+	if !digests(&Slack{NotifyOptions: NotifyOptions{Kind: "slack", Digest: digest}}, event) {
+		t.Fatalf(`digests() of a normal release = false, want match for true`)
+	}
+
+	// High severity (and WebHooks) bypass the digest.
+	event.Severities = []string{severitySecurity}
+	if digests(&Slack{NotifyOptions: NotifyOptions{Kind: "slack", Digest: digest}}, event) {
+		t.Fatalf(`digests() of a security release = true, want match for false`)
+	}
+	event.Severities = []string{severityNormal}
+	if digests(&WebHook{NotifyOptions: NotifyOptions{Kind: "webhook", Digest: digest}}, event) {
+		t.Fatalf(`digests() to a WebHook = true, want match for false`)
+	}
+}
+
+func TestStateDigests(t *testing.T) {
+	dir := t.TempDir()
+	var testState State
+	testState.init(dir)
+	testState.addDigest("slack URL", DigestEntry{ServiceID: "owner/repo", Version: "1.2.4"})
+
+	// It should be loaded again after a restart.
+	var restarted State
+	restarted.init(dir)
+	if got := restarted.getDigest("slack URL"); len(got) != 1 || got[0].Version != "1.2.4" {
+		t.Fatalf(`getDigest() = %+v, want match for 1.2.4`, got)
+	}
+
+	// Taking it empties the digest.
+	if got := restarted.takeDigest("slack URL"); len(got) != 1 {
+		t.Fatalf(`takeDigest() = %+v, want match for 1 entry`, got)
+	}
+	if got := restarted.takeDigest("slack URL"); len(got) != 0 {
+		t.Fatalf(`takeDigest() = %+v, want match for no entries`, got)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"time"
+)
+
+// ReleaseEvent is a new release found by a Service.
+//
+// It's created once when the release is found (in Monitor.check) and is only read after that,
+// so every action reports the same release, however long it's delayed or retried for.
+type ReleaseEvent struct {
+	MonitorID       string          // "SERVICE_NAME"
+	ServiceID       string          // "owner/repo"
+	ServiceType     string          // "github"/"url"
+	ServiceURL      string          // "https://github.com/owner/repo"
+	Repository      string          // "owner/repo"
+	PreviousVersion string          // "1.2.2" ("" if this is the first version found)
+	PreviousTag     string          // "v1.2.2"
+	Version         string          // "1.2.3"
+	Detected        time.Time       // When the release was found.
+	Release         GitHubRelease   // Release data from the source (type:github).
+	Notes           string          // The release notes (from Service.Changelog, or the release body).
+	Compare         *CompareSummary // What changed since PreviousVersion (Service.Compare).
+	Vulnerabilities *VulnReport     // Known vulnerabilities of PreviousVersion (Service.OSV).
+	Severity        string          // "security"/"breaking"/"normal" (the most severe of Severities).
+	Severities      []string        // Every severity of the release, e.g. ["security", "breaking"] (most severe first).
+	Signature       string          // "verified"/"unsigned"/"unchecked"/"bad" ("" without a Signature) (Service.Signature).
+	SignatureError  string          // Why the Signature isn't verified.
+	RollbackTo      string          // The version that's the latest again when Version was retracted ("" if it wasn't).
+	gotify          Gotify          // Gotify message vars of the Service to override with.
+	slack           Slack           // Slack message vars of the Service to override with.
+	digest          []DigestEntry   // The releases summarised (when this is for a digest).
+}
+
+// newReleaseEvent returns the ReleaseEvent of the latest release of svc.
+func newReleaseEvent(monitorID string, svc *Service) *ReleaseEvent {
+	event := &ReleaseEvent{
+		MonitorID:   monitorID,
+		ServiceID:   svc.ID,
+		ServiceType: svc.Type,
+		ServiceURL:  svc.getServiceURL(),
+		Repository:  svc.getRepository(),
+		gotify:      svc.Gotify,
+		slack:       svc.Slack,
+	}
+	if svc.status != nil {
+		svc.status.setEvent(event)
+	}
+	event.Notes = event.Release.Body
+	return event
+}
+
+// getTag returns the tag of the release.
+func (e *ReleaseEvent) getTag() string {
+	return valueOrValueString(e.Release.TagName, e.Version)
+}
+
+// getReleaseURL returns the web URL of the release (or the Service if there isn't one).
+func (e *ReleaseEvent) getReleaseURL() string {
+	return valueOrValueString(e.Release.HTMLURL, e.ServiceURL)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNewReleaseEvent(t *testing.T) {
+	svc := Service{
+		ID:    "owner/repo",
+		Type:  "github",
+		URL:   "owner/repo",
+		Slack: Slack{Message: "overridden"},
+	}
+	svc.status = newStatus()
+	svc.status.setVersion("1.2.2", GitHubRelease{})
+	svc.status.setVersion("1.2.3", GitHubRelease{TagName: "v1.2.3"})
+
+	event := newReleaseEvent("Monitor", &svc)
+	if event.PreviousVersion != "1.2.2" || event.Version != "1.2.3" || event.getTag() != "v1.2.3" {
+		t.Fatalf(`event = %+v, want match for 1.2.2 -> 1.2.3 (v1.2.3)`, event)
+	}
+	if event.slack.Message != "overridden" {
+		t.Fatalf(`event.slack.Message = %s, want match for overridden`, event.slack.Message)
+	}
+
+	// A later release shouldn't change the event.
+	svc.status.setVersion("1.2.4", GitHubRelease{TagName: "v1.2.4"})
+	if event.Version != "1.2.3" || event.getTag() != "v1.2.3" {
+		t.Fatalf(`event.Version = %s, want match for 1.2.3 after a later release`, event.Version)
+	}
+}
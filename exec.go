@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExecSlice is an array of Exec.
+type ExecSlice []Exec
+
+// Exec is a command to run on a new release.
+type Exec struct {
+	Command       string           `yaml:"command"`           // "docker compose pull && docker compose up -d"
+	Dir           string           `yaml:"dir,omitempty"`     // The directory to run the command in.
+	Stdin         string           `yaml:"stdin,omitempty"`   // Whether to pass the release as JSON on stdin.
+	Timeout       string           `yaml:"timeout,omitempty"` // The time to allow the command to run for before killing it.
+	NotifyOptions `yaml:",inline"` // Delay, MaxTries, Retry (timeout is ignored), SilentFails, ...
+}
+
+// UnmarshalYAML allows handling of a dict as well as a list of dicts.
+//
+// It will convert a dict to a list of a dict.
+//
+// e.g.    Exec: { command: "echo hi" }
+//
+// becomes Exec: [ { command: "echo hi" } ]
+func (e *ExecSlice) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var multi []Exec
+	err := unmarshal(&multi)
+	if err != nil {
+		var single Exec
+		err := unmarshal(&single)
+		if err != nil {
+			return err
+		}
+		*e = []Exec{single}
+	} else {
+		*e = multi
+	}
+	return nil
+}
+
+// setDefaults sets undefined variables to their default.
+func (e *ExecSlice) setDefaults(monitorID string, defaults Defaults) {
+	for index := range *e {
+		(*e)[index].setDefaults(defaults)
+	}
+	(*e).checkValues(monitorID)
+}
+
+// setDefaults sets undefined variables to their default.
+func (e *Exec) setDefaults(defaults Defaults) {
+	// Delay, MaxTries, Retry, SilentFails
+	e.NotifyOptions.setDefaults(defaults.Exec.NotifyOptions)
+
+	// Stdin
+	e.Stdin = valueOrValueString(e.Stdin, defaults.Exec.Stdin)
+	e.Stdin = stringBool(e.Stdin, "", "", false)
+
+	// Timeout
+	e.Timeout = valueOrValueString(e.Timeout, defaults.Exec.Timeout)
+}
+
+// checkValues will check the variables for all of this Monitor's Exec commands.
+func (e *ExecSlice) checkValues(monitorID string) {
+	for index := range *e {
+		(*e)[index].checkValues(monitorID, index, len(*e) == 1)
+		if (*e)[index].Command == "" {
+			msg := fmt.Sprintf("%s.exec[%d].command is required", monitorID, index)
+			jLog.Fatal(msg, true)
+		}
+	}
+}
+
+// checkValues will check that the variables are valid for this Exec command.
+func (e *Exec) checkValues(monitorID string, index int, loneService bool) {
+	target := monitorID + ".exec"
+	if !loneService {
+		target = fmt.Sprintf("%s[%d]", monitorID, index)
+	}
+	e.validate(target)
+}
+
+// validate will check that the variables are valid for this Exec command.
+func (e *Exec) validate(target string) {
+	e.NotifyOptions.validate(target)
+
+	// Timeout
+	if e.Timeout != "" {
+		// Default to seconds when an integer is provided
+		if _, err := strconv.Atoi(e.Timeout); err == nil {
+			e.Timeout += "s"
+		}
+		if _, err := time.ParseDuration(e.Timeout); err != nil {
+			msg := fmt.Sprintf("%s.timeout (%s) is invalid (Use 'AhBmCs' duration format)", target, e.Timeout)
+			jLog.Fatal(msg, true)
+		}
+	}
+}
+
+// getTarget returns the command of this Exec.
+func (e *Exec) getTarget() string {
+	return e.Command
+}
+
+// print will print this Exec command as a list element.
+func (e *Exec) print(prefix string) {
+	fmt.Printf("%s- command: '%s'\n", prefix, e.Command)
+	if e.Dir != "" {
+		fmt.Printf("%s  dir: '%s'\n", prefix, e.Dir)
+	}
+	fmt.Printf("%s  stdin: %s\n", prefix, e.Stdin)
+	fmt.Printf("%s  timeout: %s\n", prefix, e.Timeout)
+	e.NotifyOptions.print(prefix + "  ")
+}
+
+// ExecPayload is the release data passed to the command as JSON on stdin.
+type ExecPayload struct {
+	MonitorID       string          `json:"monitor_id"`                // "SERVICE_NAME"
+	ServiceID       string          `json:"service_id"`                // "owner/repo"
+	ServiceURL      string          `json:"service_url"`               // "https://github.com/owner/repo"
+	Version         string          `json:"version"`                   // "1.2.3"
+	Tag             string          `json:"tag"`                       // "v1.2.3"
+	ReleaseURL      string          `json:"release_url"`               // "https://github.com/owner/repo/releases/tag/v1.2.3"
+	ReleaseNotes    string          `json:"release_notes"`             // The release notes.
+	Compare         *CompareSummary `json:"compare,omitempty"`         // What changed since the previous version (service.compare).
+	Severity        string          `json:"severity"`                  // "security"/"breaking"/"normal"
+	Signature       string          `json:"signature,omitempty"`       // "verified" (service.signature).
+	Vulnerabilities *VulnReport     `json:"vulnerabilities,omitempty"` // Known vulnerabilities of the previous version (service.osv).
+}
+
+// newExecPayload returns the ExecPayload for the release of event.
+func newExecPayload(event *ReleaseEvent) ExecPayload {
+	return ExecPayload{
+		MonitorID:       event.MonitorID,
+		ServiceID:       event.ServiceID,
+		ServiceURL:      event.ServiceURL,
+		Version:         event.Version,
+		Tag:             event.getTag(),
+		ReleaseURL:      event.getReleaseURL(),
+		ReleaseNotes:    event.Notes,
+		Compare:         event.Compare,
+		Severity:        event.Severity,
+		Signature:       event.Signature,
+		Vulnerabilities: event.Vulnerabilities,
+	}
+}
+
+// getEnv returns the ExecPayload as environment variables.
+func (p *ExecPayload) getEnv() []string {
+	return []string{
+		"RELEASE_NOTIFIER_MONITOR_ID=" + p.MonitorID,
+		"RELEASE_NOTIFIER_SERVICE_ID=" + p.ServiceID,
+		"RELEASE_NOTIFIER_SERVICE_URL=" + p.ServiceURL,
+		"RELEASE_NOTIFIER_VERSION=" + p.Version,
+		"RELEASE_NOTIFIER_TAG=" + p.Tag,
+		"RELEASE_NOTIFIER_RELEASE_URL=" + p.ReleaseURL,
+		"RELEASE_NOTIFIER_SEVERITY=" + p.Severity,
+	}
+}
+
+// render returns the JSON of the ExecPayload for the release of event.
+//
+// Commands aren't run for custom messages, so title and message are ignored.
+func (e *Exec) render(event *ReleaseEvent, title string, message string, defaults Defaults) ([]byte, error) {
+	return json.Marshal(newExecPayload(event))
+}
+
+// send will run the Exec command with the ExecPayload of delivery.
+func (e *Exec) send(delivery *Delivery) error {
+	var payload ExecPayload
+	if err := json.Unmarshal(delivery.Payload, &payload); err != nil {
+		return &sendError{err: err, permanent: true}
+	}
+	return e.run(delivery.MonitorID, delivery.ServiceID, payload)
+}
+
+// run will run the Exec command with the release data of payload as environment variables
+// (and JSON on stdin if Exec.Stdin) and returns when an error is encountered.
+func (e *Exec) run(monitorID string, serviceID string, payload ExecPayload) error {
+	timeout, _ := time.ParseDuration(e.Timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", e.Command)
+	cmd.Dir = e.Dir
+	cmd.Env = append(os.Environ(), payload.getEnv()...)
+	if e.Stdin == "y" {
+		stdin, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	output := strings.TrimSpace(stdout.String())
+	msg := fmt.Sprintf("%s (%s), '%s' stdout:\n%s", serviceID, monitorID, e.Command, output)
+	jLog.Verbose(msg, output != "")
+
+	// FAIL
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", e.Timeout)
+		}
+		return fmt.Errorf("%s (%s), '%s' failed (%s):\n%s", serviceID, monitorID, e.Command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	// SUCCESS
+	msg = fmt.Sprintf("%s (%s), '%s' ran successfully", serviceID, monitorID, e.Command)
+	jLog.Info(msg, true)
+	output = strings.TrimSpace(stderr.String())
+	msg = fmt.Sprintf("%s (%s), '%s' stderr:\n%s", serviceID, monitorID, e.Command, output)
+	jLog.Verbose(msg, output != "")
+	return nil
+}
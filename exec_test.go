@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestExecSend(t *testing.T) {
+	payload := ExecPayload{
+		MonitorID: "Monitor",
+		ServiceID: "owner/repo",
+		Version:   "1.2.3",
+	}
+
+	// Release data should be passed as environment variables and JSON on stdin.
+	exec := Exec{
+		Command: `[ "$RELEASE_NOTIFIER_VERSION" = "1.2.3" ] && grep -q '"version":"1.2.3"'`,
+		Stdin:   "y",
+		Timeout: "5s",
+	}
+	if err := exec.run(payload.MonitorID, payload.ServiceID, payload); err != nil {
+		t.Fatalf(`exec.run() = %v, want match for <nil>`, err)
+	}
+
+	// A command outliving the timeout should fail.
+	exec = Exec{
+		Command: "exec sleep 5",
+		Timeout: "100ms",
+	}
+	if err := exec.run(payload.MonitorID, payload.ServiceID, payload); err == nil {
+		t.Fatalf(`exec.run() = %v, want an error for a timeout`, err)
+	}
+}
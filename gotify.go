@@ -7,14 +7,12 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
-	"time"
 )
 
 // GotifyExtras are the message extras (https://gotify.net/docs/msgextras) for the Gotify messages.
 type GotifyExtras struct {
 	AndroidAction      string `yaml:"android_action"`      // URL to open on notification delivery
-	ClientDisplay      string `yaml:"client_display"`      // Render message in 'text/plain' or 'text/markdown'
+	ClientDisplay      string `yaml:"client_display"`      // Render message in 'text/plain' or 'text/markdown' (default - 'text/markdown' when it has the release notes)
 	ClientNotification string `yaml:"client_notification"` // URL to open on notification click
 }
 
@@ -23,14 +21,15 @@ type GotifySlice []Gotify
 
 // Gotify is a Gotify message w/ destination and from details.
 type Gotify struct {
-	URL      string       `yaml:"url,omitempty"`       // "https://example.com
-	Token    string       `yaml:"token,omitempty"`     // apptoken
-	Title    string       `yaml:"string,omitempty"`    // "${service_id} - ${version} released"
-	Message  string       `yaml:"message,omitempty"`   // "Release notifier"
-	Extras   GotifyExtras `yaml:"extras,omitempty"`    // Message extras
-	Priority string       `yaml:"priority,omitempty"`  // <1 = Min, 1-3 = Low, 4-7 = Med, >7 = High
-	Delay    string       `yaml:"delay,omitempty"`     // The delay before sending the Gotify message.
-	MaxTries uint         `yaml:"max_tries,omitempty"` // Number of times to attempt sending the Gotify message if a 200 is not received.
+	URL              string            `yaml:"url,omitempty"`               // "https://example.com
+	Token            string            `yaml:"token,omitempty"`             // apptoken
+	Title            string            `yaml:"string,omitempty"`            // "${service_id} - ${version} released"
+	Message          string            `yaml:"message,omitempty"`           // "Release notifier"
+	Extras           GotifyExtras      `yaml:"extras,omitempty"`            // Message extras
+	Priority         string            `yaml:"priority,omitempty"`          // <1 = Min, 1-3 = Low, 4-7 = Med, >7 = High
+	NotesMaxLength   uint              `yaml:"notes_max_length,omitempty"`  // Length to truncate the release notes ({{ .notes }}) to.
+	SeverityPriority map[string]string `yaml:"severity_priority,omitempty"` // Priority to use for releases of a severity (e.g. security: 9).
+	NotifyOptions    `yaml:",inline"`  // Delay, MaxTries, Retry, ...
 }
 
 // UnmarshalYAML allows handling of a dict as well as a list of dicts.
@@ -66,18 +65,23 @@ func (g *GotifySlice) setDefaults(monitorID string, defaults Defaults) {
 
 // setDefaults sets undefined variables to their default.
 func (g *Gotify) setDefaults(defaults Defaults) {
-	// Delay
-	g.Delay = valueOrValueString(g.Delay, defaults.Gotify.Delay)
-
-	// MaxTries
-	g.MaxTries = valueOrValueUInt(g.MaxTries, defaults.Gotify.MaxTries)
+	// Delay, MaxTries, Retry, SilentFails
+	g.NotifyOptions.setDefaults(defaults.Gotify.NotifyOptions)
 
 	// Message
 	g.Message = valueOrValueString(g.Message, defaults.Gotify.Message)
 
+	// NotesMaxLength
+	g.NotesMaxLength = valueOrValueUInt(g.NotesMaxLength, defaults.Gotify.NotesMaxLength)
+
 	// Priority
 	g.Priority = valueOrValueString(g.Priority, defaults.Gotify.Priority)
 
+	// SeverityPriority
+	if g.SeverityPriority == nil {
+		g.SeverityPriority = defaults.Gotify.SeverityPriority
+	}
+
 	// Title
 	g.Title = valueOrValueString(g.Title, defaults.Gotify.Title)
 }
@@ -95,23 +99,52 @@ func (g *Gotify) checkValues(monitorID string, index int, loneService bool) {
 	if !loneService {
 		target = fmt.Sprintf("%s[%d]", monitorID, index)
 	}
+	g.validate(target)
+}
 
-	// Delay
-	if g.Delay != "" {
-		// Default to seconds when an integer is provided
-		if _, err := strconv.Atoi(g.Delay); err == nil {
-			g.Delay += "s"
-		}
-		if _, err := time.ParseDuration(g.Delay); err != nil {
-			msg := fmt.Sprintf("%s.delay '%s' is invalid (Use 'AhBmCs' duration format)", target, g.Delay)
-			jLog.Fatal(msg, true)
-		}
+// validate will check that the variables are valid for this Gotify recipient.
+func (g *Gotify) validate(target string) {
+	g.NotifyOptions.validate(target)
+	checkTemplate(g.Message, target+".message")
+	checkTemplate(g.Title, target+".title")
+	checkTemplate(g.Extras.AndroidAction, target+".extras.android_action")
+	checkTemplate(g.Extras.ClientNotification, target+".extras.client_notification")
+	switch g.Extras.ClientDisplay {
+	case "", "text/plain", "text/markdown":
+	default:
+		msg := fmt.Sprintf("%s.extras.client_display (%s) is invalid (Use 'text/plain' or 'text/markdown')", target, g.Extras.ClientDisplay)
+		jLog.Fatal(msg, true)
 	}
 
 	if _, err := strconv.Atoi(g.Priority); err != nil {
 		msg := fmt.Sprintf("%s.priority '%s' is invalid, it should be an integer, not a %T.", target, g.Priority, g.Priority)
 		jLog.Fatal(msg, true)
 	}
+
+	for severity, priority := range g.SeverityPriority {
+		checkSeverity(severity, target+".severity_priority")
+		if _, err := strconv.Atoi(priority); err != nil {
+			msg := fmt.Sprintf("%s.severity_priority.%s '%s' is invalid, it should be an integer.", target, severity, priority)
+			jLog.Fatal(msg, true)
+		}
+	}
+}
+
+// getTarget returns the URL of this Gotify recipient.
+func (g *Gotify) getTarget() string {
+	return g.URL
+}
+
+// print will print this Gotify recipient as a list element.
+func (g *Gotify) print(prefix string) {
+	fmt.Printf("%s- url: '%s'\n", prefix, g.URL)
+	fmt.Printf("%s  token: '%s'\n", prefix, g.Token)
+	fmt.Printf("%s  title: '%s'\n", prefix, g.Title)
+	fmt.Printf("%s  message: '%s'\n", prefix, g.Message)
+	fmt.Printf("%s  priority: %s\n", prefix, g.Priority)
+	printSeverityMap(prefix+"  ", "severity_priority", g.SeverityPriority)
+	fmt.Printf("%s  notes_max_length: %d\n", prefix, g.NotesMaxLength)
+	g.NotifyOptions.print(prefix + "  ")
 }
 
 // GotifyPayload is the payload to be to be sent as the Gotify message.
@@ -122,14 +155,19 @@ type GotifyPayload struct {
 	Title    string                 `form:"title" query:"title" json:"title"`
 }
 
-// HandleExtras will parse the messaging extras from 'extras' and 'defaults' into the GotifyPayload.
-func (p *GotifyPayload) HandleExtras(extras GotifyExtras, defaults GotifyExtras, serviceURL string) {
+// HandleExtras will parse the messaging extras from 'extras' and 'defaults' into the GotifyPayload
+// (rendering the URLs as templates with data).
+func (p *GotifyPayload) HandleExtras(extras GotifyExtras, defaults GotifyExtras, data map[string]interface{}) error {
 	// When received on Android and Gotify app is in focus
 	androidAction := valueOrValueString(extras.AndroidAction, defaults.AndroidAction)
 	if androidAction != "" {
+		intentURL, err := renderTemplate(androidAction, data)
+		if err != nil {
+			return err
+		}
 		p.Extras["android::action"] = map[string]interface{}{
 			"onReceive": map[string]string{
-				"intentUrl": strings.ReplaceAll(androidAction, "${service_url}", serviceURL),
+				"intentUrl": intentURL,
 			},
 		}
 	}
@@ -138,92 +176,53 @@ func (p *GotifyPayload) HandleExtras(extras GotifyExtras, defaults GotifyExtras,
 	clientDisplay := valueOrValueString(extras.ClientDisplay, defaults.ClientDisplay)
 	if clientDisplay != "" {
 		p.Extras["client::display"] = map[string]interface{}{
-			"click": map[string]string{
-				"url": strings.ReplaceAll(clientDisplay, "${service_url}", serviceURL),
-			},
+			"contentType": clientDisplay,
 		}
 	}
 
 	// When the notification is clicked (Android)
 	clientNotification := valueOrValueString(extras.ClientNotification, defaults.ClientNotification)
 	if clientNotification != "" {
+		clickURL, err := renderTemplate(clientNotification, data)
+		if err != nil {
+			return err
+		}
 		p.Extras["client::notification"] = map[string]interface{}{
 			"click": map[string]string{
-				"url": strings.ReplaceAll(clientNotification, "${service_url}", serviceURL),
+				"url": clickURL,
 			},
 		}
 	}
+	return nil
 }
 
-// send will send every gotify message in this GotifySlice.
-func (g *GotifySlice) send(monitorID string, svc *Service, title string, message string, defaults Gotify) {
-	for index := range *g {
-		// Send each Gotify message up to s.MaxTries number of times until they 200.
-		go func() {
-			index := index                    // Create new instance for the goroutine.
-			triesLeft := (*g)[index].MaxTries // Number of times to send WebHook (until 200 received).
-
-			// Delay sending the Gotify message by the defined interval.
-			sleepTime, _ := time.ParseDuration((*g)[index].Delay)
-			msg := fmt.Sprintf("%s, Sleeping for %s before sending the Gotify message", monitorID, (*g)[index].Delay)
-			jLog.Info(msg, sleepTime != 0)
-			time.Sleep(sleepTime)
-
-			for {
-				err := (*g)[index].send(monitorID, svc, title, message, defaults)
-
-				// SUCCESS!
-				if err == nil {
-					return
-				}
-
-				// FAIL
-				jLog.Error(err.Error(), true)
-				triesLeft--
-
-				// Give up after MaxTries.
-				if triesLeft == 0 {
-					msg = fmt.Sprintf("%s (%s), Failed %d times to send a Gotify message to %s", svc.ID, monitorID, (*g)[index].MaxTries, (*g)[index].URL)
-					jLog.Error(msg, true)
-					return
-				}
-
-				// Space out retries.
-				time.Sleep(10 * time.Second)
-			}
-		}()
-		// Space out Gotify messages.const.
-		time.Sleep(3 * time.Second)
-	}
-}
-
-// send sends a formatted Gotify notification regarding mon.
-func (g *Gotify) send(monitorID string, svc *Service, title string, message string, defaults Gotify) error {
-	serviceURL := svc.URL
-	// GitHub monitor. Get the non-API URL.
-	if svc.Type == "github" {
-		serviceURL = strings.Split(svc.URL, "github.com/repos/")[1]
-		serviceURL = fmt.Sprintf("https://github.com/%s/%s", strings.Split(serviceURL, "/")[0], strings.Split(serviceURL, "/")[1])
-	}
+// getPayload returns the GotifyPayload of a formatted Gotify notification regarding event.
+func (g *Gotify) getPayload(event *ReleaseEvent, title string, message string, defaults Gotify) (GotifyPayload, error) {
+	data := event.getTemplateData()
+	data["notes"] = formatNotesForGotify(event.Notes, g.NotesMaxLength)
 
 	// Use 'new release' Gotify message (Not a custom message)
+	hasNotes := false
 	if message == "" {
-		message = valueOrValueString(svc.Gotify.Message, g.Message)
-		message = strings.ReplaceAll(message, "${monitor_id}", monitorID)
-		message = strings.ReplaceAll(message, "${service_url}", serviceURL)
-		message = strings.ReplaceAll(message, "${service_id}", svc.ID)
-		message = strings.ReplaceAll(message, "${version}", svc.status.version)
-
-		title = valueOrValueString(svc.Gotify.Title, g.Title)
-		title = strings.ReplaceAll(title, "${monitor_id}", monitorID)
-		title = strings.ReplaceAll(title, "${service_url}", serviceURL)
-		title = strings.ReplaceAll(title, "${service_id}", svc.ID)
-		title = strings.ReplaceAll(title, "${version}", svc.status.version)
-	}
+		var err error
+		text := valueOrValueString(event.gotify.Message, g.Message)
+		hasNotes = event.Notes != "" && templateUses(text, "notes", "release_notes")
+		message, err = renderTemplate(text, data)
+		if err != nil {
+			return GotifyPayload{}, err
+		}
+		// Add the summary of the changes, unless the message has its own.
+		if event.Compare != nil && !templateUses(text, "compare") {
+			message += "\n" + event.Compare.format()
+		}
 
-	gotifyURL := fmt.Sprintf("%s/message?token=%s", g.URL, g.Token)
+		title, err = renderTemplate(valueOrValueString(event.gotify.Title, g.Title), data)
+		if err != nil {
+			return GotifyPayload{}, err
+		}
+	}
 
-	priority, _ := strconv.Atoi(g.Priority)
+	priority, _ := strconv.Atoi(valueOrValueString(g.SeverityPriority[event.Severity], g.Priority))
 	payload := GotifyPayload{
 		Message:  message,
 		Priority: priority,
@@ -231,29 +230,45 @@ func (g *Gotify) send(monitorID string, svc *Service, title string, message stri
 		Extras:   map[string]interface{}{},
 	}
 
-	payload.HandleExtras(g.Extras, defaults.Extras, serviceURL)
+	err := payload.HandleExtras(g.Extras, defaults.Extras, data)
+	// Render the release notes (markdown) as markdown when client_display isn't set.
+	if _, set := payload.Extras["client::display"]; hasNotes && !set {
+		payload.Extras["client::display"] = map[string]interface{}{
+			"contentType": "text/markdown",
+		}
+	}
+	return payload, err
+}
 
-	payloadData, err := json.Marshal(payload)
+// render returns the JSON of the GotifyPayload regarding event (or the custom title/message).
+func (g *Gotify) render(event *ReleaseEvent, title string, message string, defaults Defaults) ([]byte, error) {
+	payload, err := g.getPayload(event, title, message, defaults.Gotify)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return json.Marshal(payload)
+}
 
-	req, err := http.NewRequest(http.MethodPost, gotifyURL, bytes.NewReader(payloadData))
-	req.Header.Add("Content-Type", "application/json")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	req = req.WithContext(ctx)
-	defer cancel()
+// send sends the Gotify notification payload of delivery.
+func (g *Gotify) send(delivery *Delivery) error {
+	monitorID, serviceID := delivery.MonitorID, delivery.ServiceID
+	gotifyURL := fmt.Sprintf("%s/message?token=%s", g.URL, g.Token)
 
+	req, err := http.NewRequest(http.MethodPost, gotifyURL, bytes.NewReader(delivery.Payload))
 	if err != nil {
-		msg := fmt.Sprintf("%s (%s), Gotify\n%s", svc.ID, monitorID, err)
+		msg := fmt.Sprintf("%s (%s), Gotify\n%s", serviceID, monitorID, err)
 		jLog.Verbose(msg, true)
 		return err
 	}
+	req.Header.Add("Content-Type", "application/json")
+	ctx, cancel := context.WithTimeout(context.Background(), g.Retry.getTimeout())
+	req = req.WithContext(ctx)
+	defer cancel()
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		// If verbose or above, print the error every time
-		msg := fmt.Sprintf("%s (%s), Slack\n%s", svc.ID, monitorID, err)
+		msg := fmt.Sprintf("%s (%s), Gotify\n%s", serviceID, monitorID, err)
 		jLog.Verbose(msg, true)
 		return err
 	}
@@ -261,11 +276,12 @@ func (g *Gotify) send(monitorID string, svc *Service, title string, message stri
 
 	// SUCCESS (2XX)
 	if strconv.Itoa(resp.StatusCode)[:1] == "2" {
-		msg := fmt.Sprintf("%s (%s), Gotify message sent", svc.ID, monitorID)
+		msg := fmt.Sprintf("%s (%s), Gotify message sent", serviceID, monitorID)
 		jLog.Info(msg, true)
 		return nil
 	}
 
 	// FAIL
-	return fmt.Errorf("%s (%s), Gotify message failed to send.\n%s", svc.ID, monitorID, err)
+	err = fmt.Errorf("%s (%s), Gotify message failed to send.\n%s", serviceID, monitorID, resp.Status)
+	return newResponseError(resp, err)
 }
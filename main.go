@@ -1,7 +1,7 @@
 /*
 Release-Notifier monitors GitHub and/or other URLs for version changes.
-On a version change, send Slack message(s) and/or webhook(s).
-main.go uses track.go for the goroutines that call query.go
+On a version change, send to the notifier(s) (Gotify/Slack message(s), webhook(s) and/or command(s)).
+main.go uses scheduler.go for the workers that call query.go
 and then, on a version change, will call slack.go and webhook.go.
 */
 package main
@@ -11,53 +11,98 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
 var (
-	jLog JLog
+	jLog    JLog
+	limiter Limiter
+	outbox  Outbox
+	state   State
+	snoozes Snoozes
 )
 
 // Config is the config for Release-Notifier.
 type Config struct {
-	Defaults Defaults     `yaml:"defaults"` // Default values for the various parameters.
-	Monitor  MonitorSlice `yaml:"monitor"`  // The targets to monitor and notify on.
+	Defaults  Defaults     `yaml:"defaults"`   // Default values for the various parameters.
+	Scheduler Scheduler    `yaml:"scheduler"`  // How many queries to run at once.
+	RateLimit RateLimit    `yaml:"rate_limit"` // Most messages to send to the Gotify's/Slack's within a window.
+	Monitor   MonitorSlice `yaml:"monitor"`    // The targets to monitor and notify on.
 }
 
 // Defaults is the global default for vars.
 type Defaults struct {
-	Gotify  Gotify  `yaml:"gotify"`
-	Service Service `yaml:"service"`
-	Slack   Slack   `yaml:"slack"`
-	WebHook WebHook `yaml:"webhook"`
+	Exec    Exec        `yaml:"exec"`
+	Gotify  Gotify      `yaml:"gotify"`
+	Retry   RetryPolicy `yaml:"retry"` // Default RetryPolicy of every Exec/Gotify/Slack/WebHook.
+	Service Service     `yaml:"service"`
+	Slack   Slack       `yaml:"slack"`
+	WebHook WebHook     `yaml:"webhook"`
 }
 
 // setDefaults sets undefined variables to their default.
 func (d *Defaults) setDefaults() {
 	// Service defaults.
 	d.Service.AllowInvalidCerts = stringBool(d.Service.AllowInvalidCerts, "", "", false)
+	d.Service.Compare = stringBool(d.Service.Compare, "", "", false)
+	d.Service.CompareMaxPRs = valueOrValueUInt(d.Service.CompareMaxPRs, 10)
+	d.Service.Confirmations = valueOrValueUInt(d.Service.Confirmations, 1)
+	d.Service.OSV.Source = valueOrValueString(d.Service.OSV.Source, osvAPI)
+	d.Service.Assets.Verify = stringBool(d.Service.Assets.Verify, "", "", false)
+	d.Service.Assets.Checksums = valueOrValueString(d.Service.Assets.Checksums, "*SHA256SUMS*")
+	d.Service.Signature.Wait = valueOrValueString(d.Service.Signature.Wait, "1h")
 	d.Service.IgnoreMiss = stringBool(d.Service.IgnoreMiss, "", "", false)
 	d.Service.Interval = valueOrValueString(d.Service.Interval, "10m")
+	d.Service.Adaptive = stringBool(d.Service.Adaptive, "", "", false)
+	d.Service.MinInterval = valueOrValueString(d.Service.MinInterval, "5m")
+	d.Service.MaxInterval = valueOrValueString(d.Service.MaxInterval, "24h")
 	d.Service.ProgressiveVersioning = stringBool(d.Service.ProgressiveVersioning, "", "", true)
 	d.Service.checkValues("defaults", 0, true)
 
+	// Retry defaults.
+	multiplier, jitter := 2.0, 0.1
+	d.Retry.setDefaults(RetryPolicy{
+		InitialDelay: "10s",
+		Multiplier:   &multiplier,
+		MaxDelay:     "10m",
+		Jitter:       &jitter,
+		Timeout:      "5s",
+	})
+	d.Retry.checkValues("defaults")
+
+	// Exec defaults.
+	d.Exec.Delay = valueOrValueString(d.Exec.Delay, "0s")
+	d.Exec.MaxTries = valueOrValueUInt(d.Exec.MaxTries, 3)
+	d.Exec.SilentFails = stringBool(d.Exec.SilentFails, "", "", false)
+	d.Exec.Stdin = stringBool(d.Exec.Stdin, "", "", false)
+	d.Exec.Timeout = valueOrValueString(d.Exec.Timeout, "5m")
+	d.Exec.Retry.setDefaults(d.Retry)
+	d.Exec.checkValues("defaults", 0, true)
+
 	// Gotify defaults.
 	d.Gotify.Delay = valueOrValueString(d.Gotify.Delay, "0s")
+	d.Gotify.SilentFails = stringBool(d.Gotify.SilentFails, "", "", true)
 	d.Gotify.MaxTries = valueOrValueUInt(d.Gotify.MaxTries, 3)
 	d.Gotify.Message = valueOrValueString(d.Gotify.Message, "${service_id} - ${version} released")
+	d.Gotify.NotesMaxLength = valueOrValueUInt(d.Gotify.NotesMaxLength, 1000)
 	d.Gotify.Priority = valueOrValueString(d.Gotify.Priority, "5")
 	d.Gotify.Title = valueOrValueString(d.Gotify.Title, "Release notifier")
+	d.Gotify.Retry.setDefaults(d.Retry)
 	d.Gotify.checkValues("defaults", 0, true)
 
 	// Slack defaults.
 	d.Slack.Delay = valueOrValueString(d.Slack.Delay, "0s")
+	d.Slack.SilentFails = stringBool(d.Slack.SilentFails, "", "", true)
 	if d.Slack.IconEmoji == "" && d.Slack.IconURL == "" {
 		d.Slack.IconEmoji = ":github:"
 	}
 	d.Slack.MaxTries = valueOrValueUInt(d.Slack.MaxTries, 3)
 	d.Slack.Message = valueOrValueString(d.Slack.Message, "<${service_url}|${service_id}> - ${version} released")
+	d.Slack.NotesMaxLength = valueOrValueUInt(d.Slack.NotesMaxLength, 1000)
 	d.Slack.Username = valueOrValueString(d.Slack.Username, "Release Notifier")
+	d.Slack.Retry.setDefaults(d.Retry)
 	d.Slack.checkValues("defaults", 0, true)
 
 	// WebHook defaults.
@@ -65,6 +110,9 @@ func (d *Defaults) setDefaults() {
 	d.WebHook.MaxTries = valueOrValueUInt(d.WebHook.MaxTries, 3)
 	d.WebHook.DesiredStatusCode = valueOrValueInt(d.WebHook.DesiredStatusCode, 0)
 	d.WebHook.SilentFails = stringBool(d.WebHook.SilentFails, "", "", false)
+	d.WebHook.Type = strings.ToLower(valueOrValueString(d.WebHook.Type, "github"))
+	d.WebHook.Signing = strings.ToLower(d.WebHook.Signing)
+	d.WebHook.Retry.setDefaults(d.Retry)
 	d.WebHook.checkValues("defaults", 0, true)
 }
 
@@ -75,17 +123,72 @@ func (d *Defaults) print() {
 	// Service defaults.
 	fmt.Println("  service:")
 	fmt.Printf("    allow_invalid_certs: %s\n", d.Service.AllowInvalidCerts)
+	fmt.Printf("    compare: %s\n", d.Service.Compare)
+	fmt.Printf("    compare_max_prs: %d\n", d.Service.CompareMaxPRs)
+	fmt.Printf("    confirmations: %d\n", d.Service.Confirmations)
 	fmt.Printf("    ignore_miss: %s\n", d.Service.IgnoreMiss)
 	fmt.Printf("    interval: %s\n", d.Service.Interval)
+	fmt.Printf("    adaptive: %s\n", d.Service.Adaptive)
+	fmt.Printf("    min_interval: %s\n", d.Service.MinInterval)
+	fmt.Printf("    max_interval: %s\n", d.Service.MaxInterval)
+	if d.Service.Schedule != "" {
+		fmt.Printf("    schedule: '%s'\n", d.Service.Schedule)
+	}
+	if d.Service.Timezone != "" {
+		fmt.Printf("    timezone: %s\n", d.Service.Timezone)
+	}
+	if d.Service.Calendar != nil {
+		d.Service.Calendar.print("    ", "calendar")
+	}
 	fmt.Printf("    progressive_versioning: %s\n", d.Service.ProgressiveVersioning)
+	if d.Service.MinReleaseAge != "" {
+		fmt.Printf("    min_release_age: %s\n", d.Service.MinReleaseAge)
+	}
+	fmt.Println("    assets:")
+	fmt.Printf("      verify: %s\n", d.Service.Assets.Verify)
+	fmt.Printf("      checksums: '%s'\n", d.Service.Assets.Checksums)
+	d.Service.Signature.print("    ")
+	fmt.Println("    osv:")
+	if d.Service.OSV.Ecosystem != "" {
+		fmt.Printf("      ecosystem: %s\n", d.Service.OSV.Ecosystem)
+	}
+	fmt.Printf("      source: %s\n", d.Service.OSV.Source)
+
+	// Retry defaults.
+	d.Retry.print("  ")
+
+	// Exec defaults.
+	fmt.Println("  exec:")
+	fmt.Printf("    delay: %s\n", d.Exec.Delay)
+	fmt.Printf("    max_tries: %d\n", d.Exec.MaxTries)
+	fmt.Printf("    silent_fails: %s\n", d.Exec.SilentFails)
+	fmt.Printf("    stdin: %s\n", d.Exec.Stdin)
+	fmt.Printf("    timeout: %s\n", d.Exec.Timeout)
+	if d.Exec.Window != nil {
+		d.Exec.Window.print("    ", "window")
+	}
+	if d.Exec.Timezone != "" {
+		fmt.Printf("    timezone: %s\n", d.Exec.Timezone)
+	}
+	d.Exec.Retry.print("    ")
 
 	// Gotify defaults.
 	fmt.Println("  gotify:")
 	fmt.Printf("    delay: %s\n", d.Gotify.Delay)
 	fmt.Printf("    max_tries: %d\n", d.Gotify.MaxTries)
 	fmt.Printf("    message: '%s'\n", d.Gotify.Message)
+	fmt.Printf("    notes_max_length: %d\n", d.Gotify.NotesMaxLength)
 	fmt.Printf("    priority: %s\n", d.Gotify.Priority)
+	printSeverityMap("    ", "severity_priority", d.Gotify.SeverityPriority)
+	fmt.Printf("    silent_fails: %s\n", d.Gotify.SilentFails)
 	fmt.Printf("    title: '%s'\n", d.Gotify.Title)
+	if d.Gotify.Digest != nil {
+		d.Gotify.Digest.print("    ")
+	}
+	if d.Gotify.RateLimit != nil {
+		d.Gotify.RateLimit.print("    ")
+	}
+	d.Gotify.Retry.print("    ")
 	if d.Gotify.Extras != (GotifyExtras{}) {
 		fmt.Println("    extras:")
 		if d.Gotify.Extras.AndroidAction != "" {
@@ -106,14 +209,39 @@ func (d *Defaults) print() {
 	fmt.Printf("    icon_url: '%s'\n", d.Slack.IconURL)
 	fmt.Printf("    max_tries: %d\n", d.Slack.MaxTries)
 	fmt.Printf("    message: '%s'\n", d.Slack.Message)
+	fmt.Printf("    notes_max_length: %d\n", d.Slack.NotesMaxLength)
+	if d.Slack.Channel != "" {
+		fmt.Printf("    channel: '%s'\n", d.Slack.Channel)
+	}
+	printSeverityMap("    ", "severity_channel", d.Slack.SeverityChannel)
+	fmt.Printf("    silent_fails: %s\n", d.Slack.SilentFails)
 	fmt.Printf("    username: '%s'\n", d.Slack.Username)
+	if d.Slack.Digest != nil {
+		d.Slack.Digest.print("    ")
+	}
+	if d.Slack.RateLimit != nil {
+		d.Slack.RateLimit.print("    ")
+	}
+	d.Slack.Retry.print("    ")
 
 	// WebHook defaults.
 	fmt.Println("  webhook:")
 	fmt.Printf("    delay: %s\n", d.WebHook.Delay)
 	fmt.Printf("    desired_status_code: %d\n", d.WebHook.DesiredStatusCode)
 	fmt.Printf("    max_tries: %d\n", d.WebHook.MaxTries)
+	fmt.Printf("    rollback: %s\n", d.WebHook.Rollback)
 	fmt.Printf("    silent_fails: %s\n", d.WebHook.SilentFails)
+	fmt.Printf("    type: %s\n", d.WebHook.Type)
+	if d.WebHook.Signing != "" {
+		fmt.Printf("    signing: %s\n", d.WebHook.Signing)
+	}
+	if d.WebHook.Window != nil {
+		d.WebHook.Window.print("    ", "window")
+	}
+	if d.WebHook.Timezone != "" {
+		fmt.Printf("    timezone: %s\n", d.WebHook.Timezone)
+	}
+	d.WebHook.Retry.print("    ")
 }
 
 // getConf reads file as Config.
@@ -131,12 +259,18 @@ func (c *Config) getConf(file string) *Config {
 // setDefaults sets undefined variables to their default.
 func (c *Config) setDefaults() *Config {
 	c.Defaults.setDefaults()
+	c.Scheduler.setDefaults()
+	c.RateLimit.setDefaults(nil)
+	c.RateLimit.checkValues("rate_limit")
 	for monitorIndex := range c.Monitor {
 		monitor := &c.Monitor[monitorIndex]
 		monitor.Service.setDefaults(monitor.ID, c.Defaults)
+		monitor.Exec.setDefaults(monitor.ID, c.Defaults)
 		monitor.Gotify.setDefaults(monitor.ID, c.Defaults)
 		monitor.Slack.setDefaults(monitor.ID, c.Defaults)
 		monitor.WebHook.setDefaults(monitor.ID, c.Defaults)
+		monitor.Notify.setDefaults(monitor.ID, c.Defaults)
+		monitor.initNotifiers()
 	}
 	return c
 }
@@ -146,6 +280,10 @@ func (c *Config) print() {
 	c.Monitor.print()
 	fmt.Println()
 	c.Defaults.print()
+	fmt.Println()
+	c.Scheduler.print()
+	fmt.Println()
+	c.RateLimit.print("")
 }
 
 // configPrint will act on the 'config-check' flag and print the parsed
@@ -156,7 +294,7 @@ func configPrint(flag *bool, cfg *Config) {
 	}
 }
 
-// main loads the config and then calls Monitor.Track to monitor
+// main loads the config and then runs the Scheduler to monitor
 // each Service of the monitor targets for version changes and act
 // on them as defined.
 func main() {
@@ -164,8 +302,18 @@ func main() {
 		config          Config
 		configFile      = flag.String("config", "config.yml", "The path to the config file to use") // "path/to/config.yml"
 		configPrintFlag = flag.Bool("config-check", false, "Use to print the fully-parsed config")
+		dataDir         = flag.String("data", "data", "The directory to keep the outbox of pending sends and the state in (blank = don't persist)")
 		logLevel        = flag.Int("loglevel", 2, "0 = error, 1 = warn,\n2 = info,  3 = verbose,\n4 = debug")
 		timestamps      = flag.Bool("timestamps", false, "Use to enable timestamps in cli output")
+		outboxList      = flag.Bool("outbox-list", false, "Use to print the pending and dead (failed max_tries times) sends in the outbox")
+		outboxRetry     = flag.String("outbox-retry", "", "ID of a dead send in the outbox to send again ('all' = every dead send)")
+		outboxPurge     = flag.String("outbox-purge", "", "ID of a send to delete from the outbox ('all' = every dead send)")
+		snooze          = flag.String("snooze", "", "ID of a monitor/service to mute the notifications of (see -snooze-until and -snooze-notifier)")
+		snoozeUntil     = flag.String("snooze-until", "", "When -snooze ends. A time ('2006-01-02' for its start, or RFC3339), a duration ('72h'), or a semantic version to mute the releases older than (blank = until -unsnooze)")
+		snoozeNotifier  = flag.String("snooze-notifier", "", "Kind ('slack') or ID of the notifier to -snooze/-unsnooze (blank = all of them)")
+		unsnooze        = flag.String("unsnooze", "", "ID of a monitor/service to remove the snoozes of")
+		snoozeList      = flag.Bool("snooze-list", false, "Use to print the snoozes")
+		statusFlag      = flag.Bool("status", false, "Use to print the versions (and new versions waiting on confirmations) of the services, as last saved by the running Release-Notifier")
 	)
 
 	flag.Parse()
@@ -181,12 +329,18 @@ func main() {
 	// configPrint
 	configPrint(configPrintFlag, &config)
 
+	// Outbox of pending sends.
+	outbox.init(*dataDir, &config)
+	state.init(*dataDir)
+	state.restore(config.Monitor)
+	statusCLI(statusFlag, &config)
+	snoozes.init(*dataDir)
+	snoozeCLI(snooze, snoozeUntil, snoozeNotifier, unsnooze, snoozeList)
+	outboxCLI(outboxList, outboxRetry, outboxPurge)
+
 	serviceCount := 0
-	for mIndex, monitor := range config.Monitor {
+	for _, monitor := range config.Monitor {
 		serviceCount += len(monitor.Service)
-		for sIndex := range monitor.Service {
-			config.Monitor[mIndex].Service[sIndex].status.init()
-		}
 	}
 
 	if serviceCount == 0 {
@@ -214,7 +368,11 @@ func main() {
 
 	// Track all targets for changes in version and act on any
 	// found changes.
-	(&config).Monitor.track(config.Defaults)
-
-	select {}
+	// The limiter is initialised first as the outbox (alerts) and digests queue messages through it.
+	limiter.init(config.RateLimit)
+	go limiter.run(config.Defaults)
+	go outbox.replay()
+	go runDigests(config.Monitor, config.Defaults)
+	config.Scheduler.init(config.Monitor, config.Defaults)
+	config.Scheduler.run()
 }
@@ -2,8 +2,7 @@ package main
 
 import (
 	"fmt"
-	"math/rand"
-	"time"
+	"strings"
 )
 
 // MonitorSlice is an array of Monitor.
@@ -15,8 +14,12 @@ type Monitor struct {
 	ID      string       `yaml:"id"`      // "SERVICE_NAME"
 	Service ServiceSlice `yaml:"service"` // The service(s) to monitor.
 	WebHook WebHookSlice `yaml:"webhook"` // WebHook(s) to send on a new release.
+	Exec    ExecSlice    `yaml:"exec"`    // Command(s) to run on a new release.
 	Gotify  GotifySlice  `yaml:"gotify"`  // Gotify message(s) to send on a new release.
 	Slack   SlackSlice   `yaml:"slack"`   // Slack message(s) to send on a new release.
+	Notify  NotifySlice  `yaml:"notify"`  // Notifier(s) of any kind to send to on a new release.
+
+	notifiers NotifySlice // Every Notifier above (of every kind).
 }
 
 // print will print the Monitor's in the MonitorSlice
@@ -38,16 +41,50 @@ func (m *Monitor) print() {
 		fmt.Printf("        url: '%s'\n", service.URL)
 		service.URLCommands.print("        ")
 		fmt.Printf("        interval: %s\n", service.Interval)
+		if service.Adaptive == "y" {
+			fmt.Printf("        adaptive: %s\n", service.Adaptive)
+			fmt.Printf("        min_interval: %s\n", service.MinInterval)
+			fmt.Printf("        max_interval: %s\n", service.MaxInterval)
+		}
+		if service.Schedule != "" {
+			fmt.Printf("        schedule: '%s'\n", service.Schedule)
+		}
+		if service.Timezone != "" {
+			fmt.Printf("        timezone: %s\n", service.Timezone)
+		}
+		if service.Calendar != nil {
+			service.Calendar.print("        ", "calendar")
+		}
 		if service.RegexContent != "" {
 			fmt.Printf("        regex_content: %s\n", service.RegexContent)
 		}
 		if service.RegexVersion != "" {
 			fmt.Printf("        regex_version: %s\n", service.RegexVersion)
 		}
+		if service.Changelog != "" {
+			fmt.Printf("        changelog: '%s'\n", service.Changelog)
+		}
+		fmt.Printf("        compare: %s\n", service.Compare)
+		fmt.Printf("        compare_max_prs: %d\n", service.CompareMaxPRs)
+		fmt.Printf("        confirmations: %d\n", service.Confirmations)
+		if len(service.IgnoreVersions) != 0 {
+			fmt.Printf("        ignore_versions: ['%s']\n", strings.Join(service.IgnoreVersions, "', '"))
+		}
 		fmt.Printf("        progressive_versioning: %s\n", service.ProgressiveVersioning)
-		fmt.Printf("        skip_gotify: %t\n", service.SkipGotify)
-		fmt.Printf("        skip_slack: %t\n", service.SkipSlack)
-		fmt.Printf("        skip_webhook: %t\n", service.SkipWebHook)
+		if service.MinReleaseAge != "" {
+			fmt.Printf("        min_release_age: %s\n", service.MinReleaseAge)
+		}
+		service.Assets.print("        ")
+		service.Signature.print("        ")
+		if service.OSV.Package != "" {
+			fmt.Println("        osv:")
+			fmt.Printf("          ecosystem: %s\n", service.OSV.Ecosystem)
+			fmt.Printf("          package: %s\n", service.OSV.Package)
+			fmt.Printf("          source: %s\n", service.OSV.Source)
+		}
+		if len(service.Skip) != 0 {
+			fmt.Printf("        skip: [%s]\n", strings.Join(service.Skip, ", "))
+		}
 		fmt.Printf("        access_token: '%s'\n", service.AccessToken)
 		fmt.Printf("        allow_invalid: %s\n", service.AllowInvalidCerts)
 		fmt.Printf("        ignore_misses: %s\n", service.IgnoreMiss)
@@ -56,93 +93,64 @@ func (m *Monitor) print() {
 	// Gotify.
 	if len(m.Gotify) != 0 {
 		fmt.Println("    gotify:")
-		for _, gotify := range m.Gotify {
-			fmt.Printf("      - url: '%s'\n", gotify.URL)
-			fmt.Printf("        token: '%s'\n", gotify.Token)
-			fmt.Printf("        title: '%s'\n", gotify.Title)
-			fmt.Printf("        message: '%s'\n", gotify.Message)
-			fmt.Printf("        delay: %s\n", gotify.Delay)
-			fmt.Printf("        max_tries: %d\n", gotify.MaxTries)
+		for index := range m.Gotify {
+			m.Gotify[index].print("      ")
 		}
 	}
 
 	// Slack.
 	if len(m.Slack) != 0 {
 		fmt.Println("    slack:")
-		for _, slack := range m.Slack {
-			fmt.Printf("      - url: '%s'\n", slack.URL)
-			fmt.Printf("        icon_emoji: '%s'\n", slack.IconEmoji)
-			fmt.Printf("        icon_url: '%s'\n", slack.IconURL)
-			fmt.Printf("        username: '%s'\n", slack.Username)
-			fmt.Printf("        message: '%s'\n", slack.Message)
-			fmt.Printf("        delay: %s\n", slack.Delay)
-			fmt.Printf("        max_tries: %d\n", slack.MaxTries)
+		for index := range m.Slack {
+			m.Slack[index].print("      ")
 		}
 	}
 
 	// WebHook.
 	if len(m.WebHook) != 0 {
-		fmt.Println("    wwbhook:")
-		for _, webhook := range m.WebHook {
-			fmt.Printf("      - type: %s\n", webhook.Type)
-			fmt.Printf("        url: '%s'\n", webhook.URL)
-			fmt.Printf("        secret: '%s'\n", webhook.Secret)
-			fmt.Printf("        desired_status_code: %d\n", webhook.DesiredStatusCode)
-			fmt.Printf("        delay: %s\n", webhook.Delay)
-			fmt.Printf("        max_tries: %d\n", webhook.MaxTries)
-			fmt.Printf("        silent_fails: %s\n", webhook.SilentFails)
+		fmt.Println("    webhook:")
+		for index := range m.WebHook {
+			m.WebHook[index].print("      ")
 		}
 	}
-}
-
-// track will track each Monitor (in the MonitorSlice) in this ServiceSlice
-// in their own goroutines.
-func (m *MonitorSlice) track(defaults Defaults) {
-	// Loop through each service.
-	for monitorIndex := range *m {
-		for serviceIndex := range (*m)[monitorIndex].Service {
-			msg := fmt.Sprintf("Tracking %s at %s every %s", (*m)[monitorIndex].Service[serviceIndex].ID, (*m)[monitorIndex].Service[serviceIndex].URL, (*m)[monitorIndex].Service[serviceIndex].Interval)
-			jLog.Verbose(msg, true)
-
-			// Track this Service in a infinite loop goroutine.
-			go (*m)[monitorIndex].track(serviceIndex, defaults)
 
-			// Space out the tracking of each Service.
-			time.Sleep(time.Duration(rand.Intn(10)+10) * time.Second)
+	// Exec.
+	if len(m.Exec) != 0 {
+		fmt.Println("    exec:")
+		for index := range m.Exec {
+			m.Exec[index].print("      ")
 		}
 	}
-}
-
-// Track will track the Monitor.Service data and then send Slack
-// messages (Monitor.Slack) as well as WebHooks (Monitor.WebHook)
-// when a new release is spottem. It sleeps for Monitor.Interval
-// between each check.
-func (m *Monitor) track(serviceIndex int, defaults Defaults) {
-	// Track forever.
-	for {
-		// If new release found by this query.
-		if m.Service[serviceIndex].query(serviceIndex, m.ID) {
-			// Gotify(s)
-			if !m.Service[serviceIndex].SkipGotify {
-				// Send the Gotify Message(s).
-				go m.Gotify.send(m.ID, &m.Service[serviceIndex], "", "", defaults.Gotify)
-			}
 
-			// Slack(s)
-			if !m.Service[serviceIndex].SkipSlack {
-				// Send the Slack Message(s).
-				go m.Slack.send(m.ID, &m.Service[serviceIndex], "")
-			}
-
-			// WebHook(s)
-			if !m.Service[serviceIndex].SkipWebHook {
-				// Send the WebHook(s).
-				go m.WebHook.send(m.ID, m.Service[serviceIndex].ID, m.Gotify, defaults.Gotify, m.Slack)
-			}
-		}
+	// Notify.
+	if len(m.Notify) != 0 {
+		fmt.Println("    notify:")
+		m.Notify.print("      ")
+	}
+}
 
-		// Sleep interval between checks.
-		sleepTime, _ := time.ParseDuration(m.Service[serviceIndex].Interval)
-		time.Sleep(sleepTime)
+// check will query the Service at serviceIndex and then send to the
+// Notifier's of the Monitor (Slack, WebHook, ...) when a new release is spotted.
+// The Scheduler calls this each time the Service is due.
+func (m *Monitor) check(serviceIndex int, defaults Defaults) {
+	// Act on a new (or retracted) release found by this query.
+	switch m.Service[serviceIndex].query(serviceIndex, m.ID) {
+	case queryNewRelease:
+		// Send the release to every Notifier this Service doesn't skip.
+		event := newReleaseEvent(m.ID, &m.Service[serviceIndex])
+		m.Service[serviceIndex].addReleaseNotes(event)
+		m.Service[serviceIndex].addCompare(event)
+		m.Service[serviceIndex].addVulnerabilities(event)
+		event.Severities = classifySeverity(event)
+		event.Severity = event.Severities[0]
+		m.notify(&m.Service[serviceIndex], event, defaults)
+	case queryRetracted:
+		// Tell every Notifier this Service doesn't skip that it was retracted.
+		event := newReleaseEvent(m.ID, &m.Service[serviceIndex])
+		event.RollbackTo, _ = m.Service[serviceIndex].status.getLatest()
+		// Move back to the version that's the latest again, so a fix release below the retracted one is announced.
+		m.Service[serviceIndex].status.rollback(event.RollbackTo)
+		state.setService(m.ID, m.Service[serviceIndex].ID, m.Service[serviceIndex].status.getState(m.Service[serviceIndex].Confirmations))
+		m.retract(&m.Service[serviceIndex], event, defaults)
 	}
 }
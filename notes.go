@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// get returns the body of a GET of rawURL made the way this Service queries
+// (allow_invalid, and access_token when it's a GitHub URL).
+func (s *Service) get(rawURL string, accept string) ([]byte, error) {
+	reader, err := s.open(rawURL, accept, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// open returns the body of a GET of rawURL made the way this Service queries
+// (allow_invalid, and access_token when it's a GitHub URL). It must be closed.
+func (s *Service) open(rawURL string, accept string, timeout time.Duration) (io.ReadCloser, error) {
+	customTransport := &http.Transport{}
+	// HTTPS insecure skip verify.
+	if s.AllowInvalidCerts == "y" {
+		customTransport = http.DefaultTransport.(*http.Transport).Clone()
+		customTransport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	// Only send the token to GitHub.
+	if parsedURL, err := url.Parse(rawURL); err == nil && s.AccessToken != "" {
+		switch parsedURL.Host {
+		case "api.github.com", "github.com", "raw.githubusercontent.com":
+			req.Header.Set("Authorization", fmt.Sprintf("token %s", s.AccessToken))
+		}
+	}
+
+	client := &http.Client{Transport: customTransport, Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s - %s", rawURL, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// getChangelogURL returns the URL of the changelog of this Service at tag.
+//
+// A type:github Service can give the path of the changelog in the repo (e.g. "CHANGELOG.md").
+func (s *Service) getChangelogURL(tag string) string {
+	if s.Type == "github" && !strings.Contains(s.Changelog, "://") {
+		return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s", s.getRepository(), tag, strings.TrimPrefix(s.Changelog, "/"))
+	}
+	return s.Changelog
+}
+
+// addReleaseNotes will set the notes of event to the sections of the changelog of this Service
+// between the previous and new versions (when it has a changelog), otherwise the release body.
+func (s *Service) addReleaseNotes(event *ReleaseEvent) {
+	event.Notes = event.Release.Body
+	if s.Changelog == "" {
+		return
+	}
+
+	changelogURL := s.getChangelogURL(event.getTag())
+	changelog, err := s.get(changelogURL, "")
+	if err != nil {
+		msg := fmt.Sprintf("%s (%s), failed to get the changelog\n%s", s.ID, event.MonitorID, err)
+		jLog.Warn(msg, true)
+		return
+	}
+
+	notes := extractChangelog(string(changelog), event.PreviousVersion, event.Version)
+	if notes == "" {
+		msg := fmt.Sprintf("%s (%s), %s wasn't found in the changelog at %s", s.ID, event.MonitorID, event.Version, changelogURL)
+		jLog.Verbose(msg, true)
+		return
+	}
+	event.Notes = notes
+}
+
+var (
+	changelogHeading = regexp.MustCompile(`^(#{1,6})\s`)
+	changelogVersion = regexp.MustCompile(`v?([0-9]+(?:\.[0-9]+)+(?:-[0-9A-Za-z.-]+)?)`)
+)
+
+// changelogSection is the heading (and the lines under it) of a version in a changelog.
+type changelogSection struct {
+	version string   // "1.2.3" ("" if the heading isn't of a version)
+	lines   []string // The heading and the lines under it.
+}
+
+// extractChangelog returns the sections of the (markdown) changelog from the heading of version
+// down to (but not including) the heading of previousVersion.
+//
+// Only the section of version is returned if previousVersion is blank or isn't found.
+func extractChangelog(changelog string, previousVersion string, version string) string {
+	previousVersion = strings.TrimPrefix(previousVersion, "v")
+	version = strings.TrimPrefix(version, "v")
+
+	// Split into sections at the level of the first heading of a version.
+	var (
+		sections []changelogSection
+		level    string
+	)
+	for _, line := range strings.Split(strings.ReplaceAll(changelog, "\r\n", "\n"), "\n") {
+		heading := changelogHeading.FindStringSubmatch(line)
+		if heading != nil && (level == "" || heading[1] == level) {
+			if match := changelogVersion.FindStringSubmatch(line); match != nil {
+				level = heading[1]
+				sections = append(sections, changelogSection{version: match[1]})
+			} else if level != "" {
+				sections = append(sections, changelogSection{})
+			}
+		}
+		if len(sections) != 0 {
+			sections[len(sections)-1].lines = append(sections[len(sections)-1].lines, line)
+		}
+	}
+
+	start := -1
+	end := -1
+	for index, section := range sections {
+		if start == -1 && section.version == version {
+			start = index
+		} else if start != -1 && section.version == previousVersion {
+			end = index
+			break
+		}
+	}
+	if start == -1 {
+		return ""
+	}
+	if end == -1 || previousVersion == "" {
+		end = start + 1
+	}
+
+	var lines []string
+	for _, section := range sections[start:end] {
+		lines = append(lines, section.lines...)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+var (
+	notesHTMLComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+	notesBlankLines  = regexp.MustCompile(`\n{3,}`)
+)
+
+// sanitiseNotes returns the release notes without HTML comments, carriage returns or runs of blank lines.
+func sanitiseNotes(notes string) string {
+	notes = strings.ReplaceAll(notes, "\r\n", "\n")
+	notes = notesHTMLComment.ReplaceAllString(notes, "")
+	notes = notesBlankLines.ReplaceAllString(notes, "\n\n")
+	return strings.TrimSpace(notes)
+}
+
+// slackEscaper escapes the characters that have to be escaped in Slack messages.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackQuote matches an (escaped) quote at the start of a line.
+var slackQuote = regexp.MustCompile(`(?m)^&gt; ?`)
+
+// formatNotesForSlack returns the release notes sanitised, converted to Slack mrkdwn and truncated to maxLength.
+func formatNotesForSlack(notes string, maxLength uint) string {
+	notes = slackEscaper.Replace(sanitiseNotes(notes))
+	notes = slackQuote.ReplaceAllString(notes, "> ")
+	return truncate(int(maxLength), markdownToSlack(notes))
+}
+
+// formatNotesForGotify returns the release notes sanitised and truncated to maxLength.
+//
+// The markdown is left as is, as Gotify renders it with client_display: text/markdown.
+func formatNotesForGotify(notes string, maxLength uint) string {
+	return truncate(int(maxLength), sanitiseNotes(notes))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestExtractChangelog(t *testing.T) {
+	changelog := `# Changelog
+
+## [Unreleased]
+
+## [1.3.0] - 2022-02-01
+### Added
+- Feature C
+
+## v1.2.4
+- Fix B
+
+## [1.2.3] - 2022-01-01
+- Fix A
+`
+	tests := []struct {
+		previous string
+		version  string
+		want     string
+	}{
+		{"1.2.3", "1.3.0", "## [1.3.0] - 2022-02-01\n### Added\n- Feature C\n\n## v1.2.4\n- Fix B"},
+		{"v1.2.4", "v1.3.0", "## [1.3.0] - 2022-02-01\n### Added\n- Feature C"},
+		{"", "1.2.3", "## [1.2.3] - 2022-01-01\n- Fix A"},
+		// Only the new section when the previous version isn't in the changelog.
+		{"1.0.0", "1.2.4", "## v1.2.4\n- Fix B"},
+		{"1.2.3", "2.0.0", ""},
+	}
+	for _, test := range tests {
+		if got := extractChangelog(changelog, test.previous, test.version); got != test.want {
+			t.Fatalf(`extractChangelog(%q, %q) = %q, want match for %q`, test.previous, test.version, got, test.want)
+		}
+	}
+}
+
+func TestFormatNotes(t *testing.T) {
+	notes := "<!-- generated -->\r\n**Fixed** a < b\r\n\r\n\r\n\r\n> Note"
+
+	want := "*Fixed* a &lt; b\n\n> Note"
+	if got := formatNotesForSlack(notes, 100); got != want {
+		t.Fatalf(`formatNotesForSlack() = %q, want match for %q`, got, want)
+	}
+
+	want = "**Fixed**…"
+	if got := formatNotesForGotify(notes, 10); got != want {
+		t.Fatalf(`formatNotesForGotify() = %q, want match for %q`, got, want)
+	}
+}
+
+func TestGotifyNotesMarkdown(t *testing.T) {
+	event := &ReleaseEvent{ServiceID: "owner/repo", Version: "1.2.3", Notes: "**Fixed** a bug"}
+	gotify := Gotify{Message: "${service_id} ${version}\n{{ .notes }}"}
+
+	// Notes are rendered as markdown.
+	payload, err := gotify.getPayload(event, "", "", Gotify{})
+	if display, _ := payload.Extras["client::display"].(map[string]interface{}); err != nil || display["contentType"] != "text/markdown" {
+		t.Fatalf(`getPayload().Extras = %v, %v - want match for client::display text/markdown`, payload.Extras, err)
+	}
+
+	// Unless client_display is set.
+	gotify.Extras.ClientDisplay = "text/plain"
+	payload, _ = gotify.getPayload(event, "", "", Gotify{})
+	if display, _ := payload.Extras["client::display"].(map[string]interface{}); display["contentType"] != "text/plain" {
+		t.Fatalf(`getPayload().Extras = %v, want match for client::display text/plain`, payload.Extras)
+	}
+
+	// Or there are no notes in the message.
+	gotify = Gotify{Message: "${service_id} ${version}"}
+	payload, _ = gotify.getPayload(event, "", "", Gotify{})
+	if _, set := payload.Extras["client::display"]; set {
+		t.Fatalf(`getPayload().Extras = %v, want match for no client::display`, payload.Extras)
+	}
+}
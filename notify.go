@@ -0,0 +1,476 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Notifier is somewhere that new releases are sent to (e.g. a Slack message or a WebHook).
+type Notifier interface {
+	getOptions() *NotifyOptions    // The options shared by every kind of Notifier.
+	getTarget() string             // The URL/command that's sent to.
+	setDefaults(defaults Defaults) // Set undefined variables to their default.
+	validate(target string)        // Check the variables are valid (target is where in the config it is).
+	print(prefix string)           // Print the Notifier as a list element.
+
+	// render returns the payload about the release of event, or the custom title/message when given.
+	render(event *ReleaseEvent, title string, message string, defaults Defaults) ([]byte, error)
+	// send will send the payload of delivery and returns when an error is encountered.
+	send(delivery *Delivery) error
+}
+
+// notifierType is a kind of Notifier.
+type notifierType struct {
+	title     string          // "Slack"
+	name      string          // What's sent (for logs). e.g. "Slack message"
+	action    string          // What's done with the target (for logs). e.g. "send a Slack message to %s"
+	messenger bool            // Whether this kind sends messages, so can be sent alerts (e.g. failures).
+	new       func() Notifier // Returns a blank Notifier of this kind.
+}
+
+// notifierTypes are the kinds of Notifier, keyed by NotifyOptions.Kind.
+var notifierTypes = map[string]notifierType{
+	"exec": {
+		title:  "Exec",
+		name:   "command",
+		action: "run '%s'",
+		new:    func() Notifier { return &Exec{} },
+	},
+	"gotify": {
+		title:     "Gotify",
+		name:      "Gotify message",
+		action:    "send a Gotify message to %s",
+		messenger: true,
+		new:       func() Notifier { return &Gotify{} },
+	},
+	"slack": {
+		title:     "Slack",
+		name:      "Slack message",
+		action:    "send a Slack message to %s",
+		messenger: true,
+		new:       func() Notifier { return &Slack{} },
+	},
+	"webhook": {
+		title:  "WebHook",
+		name:   "WebHook",
+		action: "send a WebHook to %s",
+		new:    func() Notifier { return &WebHook{} },
+	},
+}
+
+// NotifyOptions are the options shared by every kind of Notifier.
+type NotifyOptions struct {
+	Kind           string         `yaml:"kind,omitempty"`            // "exec"/"gotify"/"slack"/"webhook" (only needed in monitor.notify).
+	ID             string         `yaml:"id,omitempty"`              // Lets a Service skip this Notifier with service.skip.
+	Services       []string       `yaml:"services,omitempty"`        // Only send for these Service IDs (default = all).
+	SkipSeverity   []string       `yaml:"skip_severity,omitempty"`   // Don't send for releases of these severities (e.g. "breaking").
+	IgnoreVersions []string       `yaml:"ignore_versions,omitempty"` // Don't send for these versions (exact, or regex of the whole version).
+	Delay          string         `yaml:"delay,omitempty"`           // The delay before sending.
+	MinReleaseAge  string         `yaml:"min_release_age,omitempty"` // WebHook/Exec only. How long the release has to stay the latest before sending (overrides service.min_release_age).
+	Window         *Calendar      `yaml:"window,omitempty"`          // WebHook/Exec only. When sends are allowed (e.g. "tue-thu" "09:00-16:00"), others wait for it to open.
+	Timezone       string         `yaml:"timezone,omitempty"`        // default - Local = Timezone of the Window (and Digest schedule), e.g. "Europe/London".
+	RateLimit      *RateLimit     `yaml:"rate_limit,omitempty"`      // Gotify/Slack only. Most messages to send to this channel within a window.
+	Digest         *Digest        `yaml:"digest,omitempty"`          // Gotify/Slack only. Batch releases into a summary message sent on a schedule (or after a quiet period).
+	MaxTries       uint           `yaml:"max_tries,omitempty"`       // Number of times to attempt sending if it fails.
+	SilentFails    string         `yaml:"silent_fails,omitempty"`    // Whether to not alert the messengers of the Monitor if this fails MaxTries times.
+	Retry          RetryPolicy    `yaml:"retry,omitempty"`           // How to space out the tries.
+	kindIndex      int            ``                                 // Index of this Notifier among those of its Kind in the Monitor.
+	location       *time.Location ``                                 // The parsed Timezone.
+}
+
+// getOptions returns the NotifyOptions.
+func (o *NotifyOptions) getOptions() *NotifyOptions {
+	return o
+}
+
+// setDefaults sets undefined variables to their default.
+func (o *NotifyOptions) setDefaults(defaults NotifyOptions) {
+	// Delay
+	o.Delay = valueOrValueString(o.Delay, defaults.Delay)
+
+	// MinReleaseAge
+	o.MinReleaseAge = valueOrValueString(o.MinReleaseAge, defaults.MinReleaseAge)
+
+	// Window
+	if o.Window == nil && defaults.Window != nil {
+		window := *defaults.Window
+		o.Window = &window
+	}
+	o.Timezone = valueOrValueString(o.Timezone, defaults.Timezone)
+
+	// RateLimit
+	if o.RateLimit == nil && defaults.RateLimit != nil {
+		rateLimit := *defaults.RateLimit
+		o.RateLimit = &rateLimit
+	}
+	if o.RateLimit != nil {
+		o.RateLimit.setDefaults(defaults.RateLimit)
+	}
+
+	// Digest
+	if o.Digest == nil && defaults.Digest != nil {
+		digest := *defaults.Digest
+		o.Digest = &digest
+	}
+	if o.Digest != nil {
+		o.Digest.setDefaults(defaults.Digest)
+	}
+
+	// MaxTries
+	o.MaxTries = valueOrValueUInt(o.MaxTries, defaults.MaxTries)
+
+	// Retry
+	o.Retry.setDefaults(defaults.Retry)
+
+	// SilentFails
+	o.SilentFails = valueOrValueString(o.SilentFails, defaults.SilentFails)
+	o.SilentFails = stringBool(o.SilentFails, "", "", false)
+}
+
+// validate will check that the variables are valid for these NotifyOptions.
+func (o *NotifyOptions) validate(target string) {
+	// Retry
+	o.Retry.checkValues(target)
+
+	// IgnoreVersions
+	checkIgnoreVersions(o.IgnoreVersions, target)
+
+	// SkipSeverity
+	for index := range o.SkipSeverity {
+		o.SkipSeverity[index] = strings.ToLower(o.SkipSeverity[index])
+		checkSeverity(o.SkipSeverity[index], fmt.Sprintf("%s.skip_severity[%d]", target, index))
+	}
+
+	// Delay
+	if o.Delay != "" {
+		// Default to seconds when an integer is provided
+		if _, err := strconv.Atoi(o.Delay); err == nil {
+			o.Delay += "s"
+		}
+		if _, err := time.ParseDuration(o.Delay); err != nil {
+			msg := fmt.Sprintf("%s.delay (%s) is invalid (Use 'AhBmCs' duration format)", target, o.Delay)
+			jLog.Fatal(msg, true)
+		}
+	}
+
+	// MinReleaseAge
+	if o.MinReleaseAge != "" {
+		if _, err := time.ParseDuration(o.MinReleaseAge); err != nil {
+			msg := fmt.Sprintf("%s.min_release_age (%s) is invalid (Use 'AhBmCs' duration format)", target, o.MinReleaseAge)
+			jLog.Fatal(msg, true)
+		}
+	}
+
+	// Timezone
+	o.location = time.Local
+	if o.Timezone != "" {
+		location, err := time.LoadLocation(o.Timezone)
+		if err != nil {
+			msg := fmt.Sprintf("%s.timezone (%s) is invalid\n%s", target, o.Timezone, err)
+			jLog.Fatal(msg, true)
+		}
+		o.location = location
+	}
+
+	// Window
+	if o.Window != nil {
+		if err := o.Window.parse(); err != nil {
+			msg := fmt.Sprintf("%s.window is invalid\n%s", target, err)
+			jLog.Fatal(msg, true)
+		}
+		if o.Window.nextOpen(time.Now(), o.location).IsZero() {
+			msg := fmt.Sprintf("%s.window doesn't open within a year", target)
+			jLog.Fatal(msg, true)
+		}
+	}
+
+	// Digest
+	if o.Digest != nil {
+		o.Digest.checkValues(target, o.location)
+	}
+
+	// RateLimit
+	if o.RateLimit != nil {
+		o.RateLimit.checkValues(target + ".rate_limit")
+	}
+}
+
+// print will print the NotifyOptions.
+func (o *NotifyOptions) print(prefix string) {
+	if o.ID != "" {
+		fmt.Printf("%sid: %s\n", prefix, o.ID)
+	}
+	if len(o.Services) != 0 {
+		fmt.Printf("%sservices: [%s]\n", prefix, strings.Join(o.Services, ", "))
+	}
+	if len(o.SkipSeverity) != 0 {
+		fmt.Printf("%sskip_severity: [%s]\n", prefix, strings.Join(o.SkipSeverity, ", "))
+	}
+	if len(o.IgnoreVersions) != 0 {
+		fmt.Printf("%signore_versions: ['%s']\n", prefix, strings.Join(o.IgnoreVersions, "', '"))
+	}
+	fmt.Printf("%sdelay: %s\n", prefix, o.Delay)
+	if o.MinReleaseAge != "" {
+		fmt.Printf("%smin_release_age: %s\n", prefix, o.MinReleaseAge)
+	}
+	if o.Window != nil {
+		o.Window.print(prefix, "window")
+	}
+	if o.Timezone != "" {
+		fmt.Printf("%stimezone: %s\n", prefix, o.Timezone)
+	}
+	if o.Digest != nil {
+		o.Digest.print(prefix)
+	}
+	if o.RateLimit != nil {
+		o.RateLimit.print(prefix)
+	}
+	fmt.Printf("%smax_tries: %d\n", prefix, o.MaxTries)
+	fmt.Printf("%ssilent_fails: %s\n", prefix, o.SilentFails)
+	o.Retry.print(prefix)
+}
+
+// NotifySlice is an array of Notifier's of any kind.
+type NotifySlice []Notifier
+
+// UnmarshalYAML allows handling of a dict as well as a list of dicts.
+//
+// Each dict is unmarshalled as the kind of Notifier in its 'kind'.
+//
+// e.g.    Notify: { kind: "slack", url: "example.com" }
+//
+// becomes Notify: [ Slack{ url: "example.com" } ]
+func (n *NotifySlice) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var nodes []yaml.Node
+	err := unmarshal(&nodes)
+	if err != nil {
+		var single yaml.Node
+		err := unmarshal(&single)
+		if err != nil {
+			return err
+		}
+		nodes = []yaml.Node{single}
+	}
+
+	*n = NotifySlice{}
+	for index := range nodes {
+		var options NotifyOptions
+		if err := nodes[index].Decode(&options); err != nil {
+			return err
+		}
+		kind := strings.ToLower(options.Kind)
+		notifierType, exists := notifierTypes[kind]
+		if !exists {
+			return fmt.Errorf("line %d: notify kind '%s' is unknown", nodes[index].Line, options.Kind)
+		}
+
+		notifier := notifierType.new()
+		if err := nodes[index].Decode(notifier); err != nil {
+			return err
+		}
+		notifier.getOptions().Kind = kind
+		*n = append(*n, notifier)
+	}
+	return nil
+}
+
+// setDefaults sets undefined variables to their default.
+func (n *NotifySlice) setDefaults(monitorID string, defaults Defaults) {
+	for index, notifier := range *n {
+		target := fmt.Sprintf("%s.notify[%d]", monitorID, index)
+		notifier.setDefaults(defaults)
+		notifier.validate(target)
+		if notifier.getTarget() == "" {
+			msg := fmt.Sprintf("%s needs a url (or command for kind:exec)", target)
+			jLog.Fatal(msg, true)
+		}
+	}
+}
+
+// print will print the Notifier's in the NotifySlice.
+func (n *NotifySlice) print(prefix string) {
+	for _, notifier := range *n {
+		notifier.print(prefix)
+		fmt.Printf("%s  kind: %s\n", prefix, notifier.getOptions().Kind)
+	}
+}
+
+// initNotifiers will gather every Notifier of this Monitor (of every kind) into Monitor.notifiers.
+func (m *Monitor) initNotifiers() {
+	m.notifiers = NotifySlice{}
+	for index := range m.Gotify {
+		m.Gotify[index].Kind = "gotify"
+		m.notifiers = append(m.notifiers, &m.Gotify[index])
+	}
+	for index := range m.Slack {
+		m.Slack[index].Kind = "slack"
+		m.notifiers = append(m.notifiers, &m.Slack[index])
+	}
+	for index := range m.WebHook {
+		m.WebHook[index].Kind = "webhook"
+		m.notifiers = append(m.notifiers, &m.WebHook[index])
+	}
+	for index := range m.Exec {
+		m.Exec[index].Kind = "exec"
+		m.notifiers = append(m.notifiers, &m.Exec[index])
+	}
+	m.notifiers = append(m.notifiers, m.Notify...)
+
+	// Index each Notifier among those of its kind.
+	kindCounts := map[string]int{}
+	for _, notifier := range m.notifiers {
+		options := notifier.getOptions()
+		options.kindIndex = kindCounts[options.Kind]
+		kindCounts[options.Kind]++
+	}
+}
+
+// getNotifier returns the Notifier of this Monitor that delivery is for.
+func (m *Monitor) getNotifier(delivery *Delivery) (Notifier, error) {
+	for _, notifier := range m.notifiers {
+		options := notifier.getOptions()
+		if options.Kind == delivery.Kind && options.kindIndex == delivery.Index && notifier.getTarget() == delivery.Target {
+			return notifier, nil
+		}
+	}
+	return nil, fmt.Errorf("%s (%s), %s %d (%s) is no longer in the config", delivery.ServiceID, delivery.MonitorID, delivery.Kind, delivery.Index, delivery.Target)
+}
+
+// skips returns whether this Service skips notifier (by kind or ID),
+// or notifier is only for other Services.
+func (s *Service) skips(notifier Notifier) bool {
+	options := notifier.getOptions()
+	for _, skip := range s.Skip {
+		if skip == options.Kind || (options.ID != "" && skip == options.ID) {
+			return true
+		}
+	}
+
+	if len(options.Services) == 0 {
+		return false
+	}
+	for _, serviceID := range options.Services {
+		if serviceID == s.ID {
+			return false
+		}
+	}
+	return true
+}
+
+// notify will queue the release of event to every Notifier of this Monitor that svc doesn't skip
+// (and that doesn't skip the severity of the release).
+//
+// A release that's unsigned (or badly signed, or couldn't be checked) isn't sent to the WebHooks/Execs (only messengers),
+// and an alert about it is sent to the messengers.
+func (m *Monitor) notify(svc *Service, event *ReleaseEvent, defaults Defaults) {
+	unverified := event.Signature == signatureUnsigned || event.Signature == signatureUnchecked || event.Signature == signatureBad
+	for _, notifier := range m.notifiers {
+		if svc.skips(notifier) || notifier.getOptions().skipsSeverity(event.Severities) || m.mutes(notifier, event) {
+			continue
+		}
+		if unverified && !notifierTypes[notifier.getOptions().Kind].messenger {
+			msg := fmt.Sprintf("%s (%s), not sending %s to %s as its signature is %s", event.ServiceID, m.ID, event.Version, notifier.getTarget(), event.Signature)
+			jLog.Warn(msg, true)
+			continue
+		}
+		// Never send a message about a version to the same place twice (e.g. a version that flaps).
+		if notifierTypes[notifier.getOptions().Kind].messenger && state.announced(getChannelKey(notifier), event.ServiceID, event.Version) {
+			msg := fmt.Sprintf("%s (%s), not sending %s to %s as it's already been sent", event.ServiceID, m.ID, event.Version, notifier.getTarget())
+			jLog.Verbose(msg, true)
+			continue
+		}
+		if digests(notifier, event) {
+			m.addDigest(notifier, event)
+			continue
+		}
+		m.queue(notifier, event, "", "", defaults)
+	}
+
+	if unverified {
+		title := fmt.Sprintf("%s %s is unsigned", event.ServiceID, event.Version)
+		switch event.Signature {
+		case signatureUnchecked:
+			title = fmt.Sprintf("%s %s's signature couldn't be checked", event.ServiceID, event.Version)
+		case signatureBad:
+			title = fmt.Sprintf("%s %s has a bad signature", event.ServiceID, event.Version)
+		}
+		msg := fmt.Sprintf("%s, so automated deployments weren't triggered.\n%s", title, event.SignatureError)
+		m.alert(event, title, msg, defaults, nil)
+	}
+}
+
+// mutes returns whether notifier ignores the version of event, or is snoozed for it.
+func (m *Monitor) mutes(notifier Notifier, event *ReleaseEvent) bool {
+	if ignoresVersion(notifier.getOptions().IgnoreVersions, event.Version) {
+		return true
+	}
+	if snoozes.mutes(event, notifier) {
+		msg := fmt.Sprintf("%s (%s), not sending %s to %s as it's snoozed", event.ServiceID, m.ID, event.Version, notifier.getTarget())
+		jLog.Verbose(msg, true)
+		return true
+	}
+	return false
+}
+
+// alert will queue the custom title/message about event to every messenger of this Monitor (except 'except').
+func (m *Monitor) alert(event *ReleaseEvent, title string, message string, defaults Defaults, except Notifier) {
+	for _, notifier := range m.notifiers {
+		if notifier != except && notifierTypes[notifier.getOptions().Kind].messenger {
+			m.queue(notifier, event, title, message, defaults)
+		}
+	}
+}
+
+// getChannelKey returns the key of where notifier sends to (its kind and URL/command, and Slack channel).
+//
+// Notifiers of every Monitor that send to the same place share a channel (e.g. for digests and rate limits).
+func getChannelKey(notifier Notifier) string {
+	key := notifier.getOptions().Kind + " " + notifier.getTarget()
+	if slack, ok := notifier.(*Slack); ok && slack.Channel != "" {
+		key += " " + slack.Channel
+	}
+	return key
+}
+
+// queue will render the payload of notifier about event (or the custom title/message) and add it to the outbox
+// (unless it's a message that's over the rate limits).
+func (m *Monitor) queue(notifier Notifier, event *ReleaseEvent, title string, message string, defaults Defaults) {
+	if notifierTypes[notifier.getOptions().Kind].messenger {
+		what := valueOrValueString(title, fmt.Sprintf("%s %s", event.ServiceID, event.Version))
+		if !limiter.allow(m, notifier, what) {
+			return
+		}
+	}
+	m.enqueue(notifier, event, title, message, defaults)
+}
+
+// enqueue will render the payload of notifier about event (or the custom title/message) and add it to the outbox
+// (ignoring the rate limits).
+func (m *Monitor) enqueue(notifier Notifier, event *ReleaseEvent, title string, message string, defaults Defaults) {
+	options := notifier.getOptions()
+	payload, err := notifier.render(event, title, message, defaults)
+	if err != nil {
+		msg := fmt.Sprintf("%s (%s), %s payload failed to build\n%s", event.ServiceID, m.ID, notifierTypes[options.Kind].title, err)
+		jLog.Error(msg, true)
+		return
+	}
+	delivery := newDelivery(options.Kind, m.ID, options.kindIndex, notifier.getTarget(), event.ServiceID, payload, options.Delay)
+	// The releases are only recorded as sent to a messenger once they have been.
+	if notifierTypes[options.Kind].messenger {
+		if title == "" && message == "" {
+			delivery.Announces = append(delivery.Announces, Announcement{ServiceID: event.ServiceID, Version: event.Version})
+		}
+		for _, entry := range event.digest {
+			delivery.Announces = append(delivery.Announces, Announcement{ServiceID: entry.ServiceID, Version: entry.Version})
+		}
+	}
+	if title == "" && message == "" {
+		m.setSoak(delivery, notifier, event)
+		m.setWindow(delivery, notifier, event, defaults)
+	}
+	outbox.add(delivery)
+}
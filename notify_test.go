@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestNotifySliceUnmarshal(t *testing.T) {
+	var monitor Monitor
+	data := `
+id: Monitor
+notify:
+  - kind: slack
+    url: https://slack.example.com
+  - kind: webhook
+    id: deploy
+    type: gitlab
+    url: https://hook.example.com
+    max_tries: 5
+`
+	if err := yaml.Unmarshal([]byte(data), &monitor); err != nil {
+		t.Fatalf(`yaml.Unmarshal() = %v, want match for <nil>`, err)
+	}
+	if len(monitor.Notify) != 2 {
+		t.Fatalf(`len(monitor.Notify) = %d, want match for 2`, len(monitor.Notify))
+	}
+	if slack, ok := monitor.Notify[0].(*Slack); !ok || slack.URL != "https://slack.example.com" {
+		t.Fatalf(`monitor.Notify[0] = %#v, want match for a Slack to https://slack.example.com`, monitor.Notify[0])
+	}
+	webhook, ok := monitor.Notify[1].(*WebHook)
+	if !ok || webhook.Type != "gitlab" || webhook.ID != "deploy" || webhook.MaxTries != 5 {
+		t.Fatalf(`monitor.Notify[1] = %#v, want match for a gitlab WebHook with id deploy and max_tries 5`, monitor.Notify[1])
+	}
+
+	// Unknown kinds should fail.
+	data = "notify: { kind: fax, url: https://example.com }"
+	if err := yaml.Unmarshal([]byte(data), &monitor); err == nil {
+		t.Fatalf(`yaml.Unmarshal() = %v, want an error for kind:fax`, err)
+	}
+}
+
+func TestServiceSkips(t *testing.T) {
+	svc := Service{ID: "owner/repo", Skip: []string{"slack", "deploy"}}
+	tests := []struct {
+		notifier Notifier
+		want     bool
+	}{
+		{&Slack{NotifyOptions: NotifyOptions{Kind: "slack"}}, true},
+		{&Gotify{NotifyOptions: NotifyOptions{Kind: "gotify"}}, false},
+		{&WebHook{NotifyOptions: NotifyOptions{Kind: "webhook", ID: "deploy"}}, true},
+		{&Exec{NotifyOptions: NotifyOptions{Kind: "exec", Services: []string{"other/repo"}}}, true},
+		{&Exec{NotifyOptions: NotifyOptions{Kind: "exec", Services: []string{"owner/repo"}}}, false},
+	}
+	for index, test := range tests {
+		if got := svc.skips(test.notifier); got != test.want {
+			t.Fatalf(`%d: svc.skips() = %t, want match for %t`, index, got, test.want)
+		}
+	}
+}
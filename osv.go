@@ -0,0 +1,310 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OSV is where to look up the known vulnerabilities of the deployed version of a Service.
+type OSV struct {
+	Ecosystem string `yaml:"ecosystem,omitempty"` // "Go"/"npm"/"PyPI"/... (https://ossf.github.io/osv-schema/#affectedpackage-field)
+	Package   string `yaml:"package,omitempty"`   // "github.com/owner/repo"
+	Source    string `yaml:"source,omitempty"`    // The OSV API query URL, or the path of a local OSV zip export (e.g. "/data/osv/Go.zip").
+}
+
+// osvAPI is the default OSV.Source.
+const osvAPI = "https://api.osv.dev/v1/query"
+
+// setDefaults sets undefined variables to their default.
+func (o *OSV) setDefaults(defaults OSV) {
+	o.Ecosystem = valueOrValueString(o.Ecosystem, defaults.Ecosystem)
+	o.Source = valueOrValueString(o.Source, defaults.Source)
+}
+
+// VulnReport is the known vulnerabilities of a version.
+type VulnReport struct {
+	Version         string `json:"version"`         // The version that was looked up (the deployed one).
+	Vulnerabilities []Vuln `json:"vulnerabilities"` // The vulnerabilities affecting Version.
+	FixedIn         string `json:"fixed_in"`        // The minimum version that fixes all of them ("" if unknown).
+}
+
+// Vuln is a known vulnerability.
+type Vuln struct {
+	ID      string   `json:"id"`       // "GHSA-xxxx-xxxx-xxxx"
+	Aliases []string `json:"aliases"`  // ["CVE-2022-12345"]
+	Summary string   `json:"summary"`  // "Denial of service in ..."
+	FixedIn string   `json:"fixed_in"` // The minimum version after the looked up one that fixes it ("" if unknown).
+}
+
+// osvVuln is the subset of an OSV vulnerability (https://ossf.github.io/osv-schema) that is used.
+type osvVuln struct {
+	ID       string   `json:"id"`
+	Aliases  []string `json:"aliases"`
+	Summary  string   `json:"summary"`
+	Affected []struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+		} `json:"package"`
+		Ranges []struct {
+			Type   string `json:"type"`
+			Events []struct {
+				Introduced   string `json:"introduced,omitempty"`
+				Fixed        string `json:"fixed,omitempty"`
+				LastAffected string `json:"last_affected,omitempty"`
+			} `json:"events"`
+		} `json:"ranges"`
+		Versions []string `json:"versions"`
+	} `json:"affected"`
+}
+
+// addVulnerabilities will set the VulnReport of event to the known vulnerabilities
+// of the previous (deployed) version (when this Service has an OSV package).
+func (s *Service) addVulnerabilities(event *ReleaseEvent) {
+	if s.OSV.Package == "" || event.PreviousVersion == "" {
+		return
+	}
+
+	report, err := s.OSV.lookup(event.PreviousVersion)
+	if err != nil {
+		msg := fmt.Sprintf("%s (%s), failed to look up the vulnerabilities of %s in OSV\n%s", s.ID, event.MonitorID, event.PreviousVersion, err)
+		jLog.Warn(msg, true)
+		return
+	}
+	event.Vulnerabilities = report
+}
+
+// lookup returns the VulnReport of the known vulnerabilities of version of the package.
+func (o *OSV) lookup(version string) (*VulnReport, error) {
+	version = strings.TrimPrefix(version, "v")
+	var (
+		vulns []osvVuln
+		err   error
+	)
+	if strings.Contains(o.Source, "://") {
+		vulns, err = o.queryAPI(version)
+	} else {
+		vulns, err = o.queryZip(version)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VulnReport{Version: version, Vulnerabilities: []Vuln{}}
+	for _, vuln := range vulns {
+		fixedIn := o.getFixedIn(vuln, version)
+		report.Vulnerabilities = append(report.Vulnerabilities, Vuln{
+			ID:      vuln.ID,
+			Aliases: vuln.Aliases,
+			Summary: vuln.Summary,
+			FixedIn: fixedIn,
+		})
+		if fixedIn != "" && (report.FixedIn == "" || compareVersions(fixedIn, report.FixedIn) > 0) {
+			report.FixedIn = fixedIn
+		}
+	}
+	sort.Slice(report.Vulnerabilities, func(i, j int) bool {
+		return report.Vulnerabilities[i].ID < report.Vulnerabilities[j].ID
+	})
+	return report, nil
+}
+
+// queryAPI returns the vulnerabilities affecting version of the package from the OSV API.
+func (o *OSV) queryAPI(version string) ([]osvVuln, error) {
+	query, _ := json.Marshal(map[string]interface{}{
+		"version": version,
+		"package": map[string]string{
+			"name":      o.Package,
+			"ecosystem": o.Ecosystem,
+		},
+	})
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(o.Source, "application/json", bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("POST %s - %s\n%s", o.Source, resp.Status, body)
+	}
+
+	var result struct {
+		Vulns []osvVuln `json:"vulns"`
+	}
+	err = json.Unmarshal(body, &result)
+	return result.Vulns, err
+}
+
+// queryZip returns the vulnerabilities affecting version of the package from a local OSV zip export
+// (a zip of OSV JSON files, e.g. https://osv-vulnerabilities.storage.googleapis.com/Go/all.zip).
+func (o *OSV) queryZip(version string) ([]osvVuln, error) {
+	archive, err := zip.OpenReader(o.Source)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	var vulns []osvVuln
+	for _, file := range archive.File {
+		if filepath.Ext(file.Name) != ".json" {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		var vuln osvVuln
+		if err := json.Unmarshal(data, &vuln); err != nil {
+			continue
+		}
+		if o.affects(vuln, version) {
+			vulns = append(vulns, vuln)
+		}
+	}
+	return vulns, nil
+}
+
+// matches returns whether the affected package (ecosystem and name) is this package.
+func (o *OSV) matches(ecosystem string, name string) bool {
+	// Ecosystems can have a suffix (e.g. "Debian:11").
+	return name == o.Package && (o.Ecosystem == "" || strings.SplitN(ecosystem, ":", 2)[0] == o.Ecosystem)
+}
+
+// affects returns whether vuln affects version of this package.
+func (o *OSV) affects(vuln osvVuln, version string) bool {
+	for _, affected := range vuln.Affected {
+		if !o.matches(affected.Package.Ecosystem, affected.Package.Name) {
+			continue
+		}
+		for _, affectedVersion := range affected.Versions {
+			if strings.TrimPrefix(affectedVersion, "v") == version {
+				return true
+			}
+		}
+		for _, versionRange := range affected.Ranges {
+			if versionRange.Type == "GIT" {
+				continue
+			}
+			isAffected := false
+			for _, event := range versionRange.Events {
+				switch {
+				case event.Introduced != "":
+					if event.Introduced == "0" || compareVersions(version, event.Introduced) >= 0 {
+						isAffected = true
+					}
+				case event.Fixed != "":
+					if compareVersions(version, event.Fixed) >= 0 {
+						isAffected = false
+					}
+				case event.LastAffected != "":
+					if compareVersions(version, event.LastAffected) > 0 {
+						isAffected = false
+					}
+				}
+			}
+			if isAffected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getFixedIn returns the minimum version after version that vuln is fixed in for this package ("" if unknown).
+func (o *OSV) getFixedIn(vuln osvVuln, version string) string {
+	fixedIn := ""
+	for _, affected := range vuln.Affected {
+		if !o.matches(affected.Package.Ecosystem, affected.Package.Name) {
+			continue
+		}
+		for _, versionRange := range affected.Ranges {
+			if versionRange.Type == "GIT" {
+				continue
+			}
+			for _, event := range versionRange.Events {
+				fixed := strings.TrimPrefix(event.Fixed, "v")
+				if fixed != "" && compareVersions(fixed, version) > 0 && (fixedIn == "" || compareVersions(fixed, fixedIn) < 0) {
+					fixedIn = fixed
+				}
+			}
+		}
+	}
+	return fixedIn
+}
+
+// compareVersions returns -1, 0 or 1 when version a is older, the same as or newer than version b.
+//
+// The dot-separated parts are compared numerically where they're numbers (missing parts are 0),
+// and a pre-release ("1.2.3-rc1") is older than its release.
+func compareVersions(a string, b string) int {
+	a = strings.TrimPrefix(a, "v")
+	b = strings.TrimPrefix(b, "v")
+	aParts := strings.SplitN(a, "-", 2)
+	bParts := strings.SplitN(b, "-", 2)
+
+	if result := compareVersionParts(strings.Split(aParts[0], "."), strings.Split(bParts[0], ".")); result != 0 {
+		return result
+	}
+
+	// Pre-releases.
+	switch {
+	case len(aParts) == 1 && len(bParts) == 1:
+		return 0
+	case len(aParts) == 1:
+		return 1
+	case len(bParts) == 1:
+		return -1
+	}
+	return compareVersionParts(strings.Split(aParts[1], "."), strings.Split(bParts[1], "."))
+}
+
+// compareVersionParts returns -1, 0 or 1 when the parts of a are older, the same as or newer than those of b.
+func compareVersionParts(a []string, b []string) int {
+	for index := 0; index < len(a) || index < len(b); index++ {
+		aPart, bPart := "0", "0"
+		if index < len(a) {
+			aPart = a[index]
+		}
+		if index < len(b) {
+			bPart = b[index]
+		}
+
+		aNumber, aErr := strconv.ParseUint(aPart, 10, 64)
+		bNumber, bErr := strconv.ParseUint(bPart, 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNumber != bNumber {
+				if aNumber < bNumber {
+					return -1
+				}
+				return 1
+			}
+		case aPart != bPart:
+			if aPart < bPart {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.2.3", "1.10.0", -1},
+		{"1.4.2", "1.4.1", 1},
+		{"1.2.3-rc1", "1.2.3", -1},
+		{"1.2.3-rc.2", "1.2.3-rc.10", -1},
+	}
+
+	for _, test := range tests {
+		if got := compareVersions(test.a, test.b); got != test.want {
+			t.Fatalf(`compareVersions(%q, %q) = %d, want match for %d`, test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestOSVLookupZip(t *testing.T) {
+	vulns := map[string]string{
+		// Affects 1.3.0, fixed in 1.4.2.
+		"GHSA-aaaa.json": `{"id": "GHSA-aaaa", "aliases": ["CVE-2022-0001"], "summary": "Denial of service",
+			"affected": [{"package": {"ecosystem": "Go", "name": "github.com/owner/repo"},
+				"ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.2.0"}, {"introduced": "1.3.0"}, {"fixed": "1.4.2"}]}]}]}`,
+		// Affects 1.3.0, fixed in 1.3.5 (and a later branch).
+		"GHSA-bbbb.json": `{"id": "GHSA-bbbb", "summary": "Path traversal",
+			"affected": [{"package": {"ecosystem": "Go", "name": "github.com/owner/repo"},
+				"ranges": [{"type": "SEMVER", "events": [{"introduced": "1.0.0"}, {"fixed": "1.3.5"}, {"fixed": "1.4.0"}]}]}]}`,
+		// Fixed before 1.3.0.
+		"GHSA-cccc.json": `{"id": "GHSA-cccc", "summary": "Old",
+			"affected": [{"package": {"ecosystem": "Go", "name": "github.com/owner/repo"},
+				"ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.1.0"}]}]}]}`,
+		// Another package.
+		"GHSA-dddd.json": `{"id": "GHSA-dddd", "summary": "Other",
+			"affected": [{"package": {"ecosystem": "Go", "name": "github.com/owner/other"},
+				"versions": ["1.3.0"]}]}`,
+	}
+	path := filepath.Join(t.TempDir(), "Go.zip")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := zip.NewWriter(file)
+	for name, data := range vulns {
+		writer, _ := archive.Create(name)
+		writer.Write([]byte(data))
+	}
+	archive.Close()
+	file.Close()
+
+	osv := OSV{Ecosystem: "Go", Package: "github.com/owner/repo", Source: path}
+	got, err := osv.lookup("v1.3.0")
+	if err != nil {
+		t.Fatalf(`lookup() = %v, want match for <nil>`, err)
+	}
+	if len(got.Vulnerabilities) != 2 || got.Vulnerabilities[0].ID != "GHSA-aaaa" || got.Vulnerabilities[1].ID != "GHSA-bbbb" {
+		t.Fatalf(`lookup().Vulnerabilities = %+v, want match for GHSA-aaaa and GHSA-bbbb`, got.Vulnerabilities)
+	}
+	if got.Vulnerabilities[0].FixedIn != "1.4.2" || got.Vulnerabilities[1].FixedIn != "1.3.5" {
+		t.Fatalf(`lookup().Vulnerabilities = %+v, want match for fixed in 1.4.2 and 1.3.5`, got.Vulnerabilities)
+	}
+	if got.FixedIn != "1.4.2" {
+		t.Fatalf(`lookup().FixedIn = %q, want match for "1.4.2"`, got.FixedIn)
+	}
+
+	// The fix classes the release as security.
+	event := &ReleaseEvent{PreviousVersion: "1.3.0", Version: "1.5.0", Vulnerabilities: got}
+	if severities := classifySeverity(event); severities[0] != severitySecurity {
+		t.Fatalf(`classifySeverity() = %v, want match for %q`, severities, severitySecurity)
+	}
+}
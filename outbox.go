@@ -0,0 +1,404 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Delivery is a queued send of a notification/WebHook/command along with its progress.
+type Delivery struct {
+	ID            string          `json:"id"`                        // Unique ID (also used as the WebHook delivery ID).
+	Kind          string          `json:"kind"`                      // "exec"/"gotify"/"slack"/"webhook"
+	MonitorID     string          `json:"monitor_id"`                // ID of the Monitor the target belongs to.
+	Index         int             `json:"index"`                     // Index of the target among the Notifier's of its Kind in the Monitor.
+	Target        string          `json:"target"`                    // URL/command of the target (to detect config changes).
+	ServiceID     string          `json:"service_id"`                // ID of the Service that triggered this Delivery.
+	Payload       json.RawMessage `json:"payload"`                   // The rendered payload to send.
+	Scheduled     time.Time       `json:"scheduled"`                 // When to (next) try sending the Delivery.
+	Version       string          `json:"version,omitempty"`         // The version the Delivery is about (set when it has to soak).
+	MinReleaseAge string          `json:"min_release_age,omitempty"` // How long Version has to stay the latest before sending.
+	Attempts      uint            `json:"attempts"`                  // Number of tries so far.
+	LastError     string          `json:"last_error,omitempty"`      // The error of the last try.
+	Announces     []Announcement  `json:"announces,omitempty"`       // The releases to record as sent to the channel once this is (Gotify/Slack).
+}
+
+// Announcement is a version of a Service that a Delivery announces.
+type Announcement struct {
+	ServiceID string `json:"service_id"` // "owner/repo"
+	Version   string `json:"version"`    // "1.2.3"
+}
+
+// newDelivery returns a Delivery of payload to the target at index of the kind of the Monitor,
+// scheduled for after delay.
+func newDelivery(kind string, monitorID string, index int, target string, serviceID string, payload []byte, delay string) *Delivery {
+	sleepTime, _ := time.ParseDuration(delay)
+	return &Delivery{
+		ID:        randDeliveryID(),
+		Kind:      kind,
+		MonitorID: monitorID,
+		Index:     index,
+		Target:    target,
+		ServiceID: serviceID,
+		Payload:   payload,
+		Scheduled: time.Now().Add(sleepTime),
+	}
+}
+
+// getMonitor returns the Monitor with the ID monitorID (nil if there isn't one).
+func (m *MonitorSlice) getMonitor(monitorID string) *Monitor {
+	for index := range *m {
+		if (*m)[index].ID == monitorID {
+			return &(*m)[index]
+		}
+	}
+	return nil
+}
+
+// getService returns the Service with the ID serviceID (nil if there isn't one).
+func (m *Monitor) getService(serviceID string) *Service {
+	for index := range m.Service {
+		if m.Service[index].ID == serviceID {
+			return &m.Service[index]
+		}
+	}
+	return nil
+}
+
+// Outbox is the queue of Delivery's. Each Delivery is written to disk before it's
+// sent so that it survives restarts, and is moved to the dead-letter list when it fails.
+type Outbox struct {
+	dir    string          // Directory to persist the Delivery's to ("" = don't persist).
+	config *Config         // The config the targets of the Delivery's are in.
+	active map[string]bool // IDs of the Delivery's being sent.
+	mutex  sync.Mutex      // Lock for active and the files.
+}
+
+const (
+	outboxPending = "pending" // Directory of the Delivery's waiting to be sent.
+	outboxDead    = "dead"    // Directory of the Delivery's that failed maxTries times.
+)
+
+// init will initialise the Outbox to persist to dir.
+//
+// A blank dir (or one that can't be created) will only queue in memory.
+func (o *Outbox) init(dir string, config *Config) {
+	o.config = config
+	o.active = map[string]bool{}
+	if dir == "" {
+		return
+	}
+
+	for _, state := range []string{outboxPending, outboxDead} {
+		if err := os.MkdirAll(filepath.Join(dir, state), 0o755); err != nil {
+			msg := fmt.Sprintf("Failed to create the outbox at '%s', so pending sends won't survive restarts\n%s", dir, err)
+			jLog.Error(msg, true)
+			return
+		}
+	}
+	o.dir = dir
+}
+
+// getPath returns the path of the file of the Delivery with ID id in state.
+func (o *Outbox) getPath(state string, id string) string {
+	return filepath.Join(o.dir, state, id+".json")
+}
+
+// save will write delivery to the file for it in state.
+func (o *Outbox) save(state string, delivery *Delivery) {
+	if o.dir == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(delivery, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(o.getPath(state, delivery.ID), data)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("%s (%s), Failed to save %s to the outbox\n%s", delivery.ServiceID, delivery.MonitorID, delivery.ID, err)
+		jLog.Error(msg, true)
+	}
+}
+
+// remove will delete the file of the Delivery with ID id in state.
+func (o *Outbox) remove(state string, id string) error {
+	if o.dir == "" {
+		return nil
+	}
+	return os.Remove(o.getPath(state, id))
+}
+
+// exists returns whether the Delivery with ID id is still in state (e.g. it's not been purged).
+func (o *Outbox) exists(state string, id string) bool {
+	if o.dir == "" {
+		return true
+	}
+	_, err := os.Stat(o.getPath(state, id))
+	return err == nil
+}
+
+// list returns the Delivery's in state, oldest first.
+func (o *Outbox) list(state string) ([]Delivery, error) {
+	var deliveries []Delivery
+	if o.dir == "" {
+		return deliveries, nil
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(o.dir, state))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(o.dir, state, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var delivery Delivery
+		if err := json.Unmarshal(data, &delivery); err != nil {
+			msg := fmt.Sprintf("Skipping '%s' in the outbox as it's invalid\n%s", file.Name(), err)
+			jLog.Warn(msg, true)
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].Scheduled.Before(deliveries[j].Scheduled)
+	})
+	return deliveries, nil
+}
+
+// add will write delivery to the outbox and start sending it.
+func (o *Outbox) add(delivery *Delivery) {
+	o.mutex.Lock()
+	o.save(outboxPending, delivery)
+	o.mutex.Unlock()
+	o.start(delivery)
+}
+
+// start will send delivery in a goroutine (unless it's already being sent).
+func (o *Outbox) start(delivery *Delivery) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.active[delivery.ID] {
+		return
+	}
+	o.active[delivery.ID] = true
+	go o.send(delivery)
+}
+
+// replay will start sending every pending Delivery in the outbox (e.g. after a restart),
+// and then keep checking for ones added by the CLI (e.g. '-outbox-retry').
+func (o *Outbox) replay() {
+	for {
+		deliveries, err := o.list(outboxPending)
+		if err != nil {
+			msg := fmt.Sprintf("Failed to read the outbox\n%s", err)
+			jLog.Error(msg, true)
+		}
+		for index := range deliveries {
+			o.start(&deliveries[index])
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
+// send will send delivery once it's scheduled, retrying until it succeeds or fails maxTries times.
+func (o *Outbox) send(delivery *Delivery) {
+	defer func() {
+		o.mutex.Lock()
+		delete(o.active, delivery.ID)
+		o.mutex.Unlock()
+	}()
+
+	monitor := o.config.Monitor.getMonitor(delivery.MonitorID)
+	var notifier Notifier
+	err := fmt.Errorf("%s (%s), monitor is no longer in the config", delivery.ServiceID, delivery.MonitorID)
+	if monitor != nil {
+		notifier, err = monitor.getNotifier(delivery)
+	}
+	if err != nil {
+		jLog.Error(err.Error(), true)
+		o.kill(delivery, err)
+		return
+	}
+	options := notifier.getOptions()
+	kind := notifierTypes[delivery.Kind]
+
+	// Delay sending by the defined interval.
+	if sleepTime := time.Until(delivery.Scheduled); sleepTime > 0 {
+		msg := fmt.Sprintf("%s (%s), Sleeping for %s before sending the %s", delivery.ServiceID, delivery.MonitorID, sleepTime.Round(time.Second), kind.name)
+		jLog.Info(msg, true)
+		time.Sleep(sleepTime)
+	}
+
+	// Wait for the release to soak (cancelling if it's superseded or retracted).
+	if !o.soak(monitor, delivery, kind) {
+		return
+	}
+
+	for {
+		// Wait for the deploy window to open (it may have closed while soaking or retrying).
+		o.window(notifier, delivery, kind)
+
+		// Stop if it's been purged.
+		if !o.exists(outboxPending, delivery.ID) {
+			msg := fmt.Sprintf("%s (%s), %s %s is no longer in the outbox (purged)", delivery.ServiceID, delivery.MonitorID, kind.name, delivery.ID)
+			jLog.Info(msg, true)
+			return
+		}
+
+		err := notifier.send(delivery)
+		delivery.Attempts++
+
+		// SUCCESS!
+		if err == nil {
+			o.mutex.Lock()
+			o.remove(outboxPending, delivery.ID)
+			o.mutex.Unlock()
+			for _, announcement := range delivery.Announces {
+				state.announce(getChannelKey(notifier), announcement.ServiceID, announcement.Version)
+			}
+			return
+		}
+
+		// FAIL!
+		jLog.Error(err.Error(), true)
+		delivery.LastError = err.Error()
+		retry, delay := options.Retry.next(delivery.Attempts, options.MaxTries, err)
+
+		// Give up after maxTries (or a permanent failure).
+		if !retry {
+			o.kill(delivery, err)
+			msg := fmt.Sprintf("Failed %d times to "+kind.action, delivery.Attempts, notifier.getTarget())
+			if options.SilentFails != "y" {
+				event := &ReleaseEvent{MonitorID: monitor.ID, ServiceID: delivery.ServiceID}
+				if svc := monitor.getService(delivery.ServiceID); svc != nil {
+					event = newReleaseEvent(monitor.ID, svc)
+				}
+				monitor.alert(event, kind.title+" fail", msg, o.config.Defaults, notifier)
+			}
+			msg = fmt.Sprintf("%s (%s), %s", delivery.ServiceID, delivery.MonitorID, msg)
+			jLog.Error(msg, true)
+			return
+		}
+
+		// Space out retries.
+		delivery.Scheduled = time.Now().Add(delay)
+		o.mutex.Lock()
+		o.save(outboxPending, delivery)
+		o.mutex.Unlock()
+		time.Sleep(delay)
+	}
+}
+
+// kill will move delivery to the dead-letter list.
+func (o *Outbox) kill(delivery *Delivery, err error) {
+	delivery.LastError = err.Error()
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.save(outboxDead, delivery)
+	o.remove(outboxPending, delivery.ID)
+}
+
+// print will print the Delivery's in the outbox.
+func (o *Outbox) print() {
+	for _, state := range []string{outboxPending, outboxDead} {
+		deliveries, err := o.list(state)
+		jLog.Fatal(fmt.Sprintf("Failed to read the outbox\n%s", err), err != nil)
+
+		fmt.Printf("%s: (%d)\n", state, len(deliveries))
+		for _, delivery := range deliveries {
+			fmt.Printf("  - id: %s\n", delivery.ID)
+			fmt.Printf("    kind: %s\n", delivery.Kind)
+			fmt.Printf("    monitor_id: %s\n", delivery.MonitorID)
+			fmt.Printf("    service_id: %s\n", delivery.ServiceID)
+			fmt.Printf("    target: '%s'\n", delivery.Target)
+			fmt.Printf("    scheduled: %s\n", delivery.Scheduled.Format(time.RFC3339))
+			fmt.Printf("    attempts: %d\n", delivery.Attempts)
+			if delivery.LastError != "" {
+				fmt.Printf("    last_error: '%s'\n", strings.ReplaceAll(delivery.LastError, "\n", " "))
+			}
+		}
+	}
+}
+
+// retryDead will move the dead Delivery with ID id (or all of them if id is "all")
+// back to pending to be sent again. It returns the number moved.
+func (o *Outbox) retryDead(id string) (int, error) {
+	deliveries, err := o.list(outboxDead)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for index := range deliveries {
+		delivery := &deliveries[index]
+		if id != "all" && delivery.ID != id {
+			continue
+		}
+		delivery.Attempts = 0
+		delivery.LastError = ""
+		delivery.Scheduled = time.Now()
+		o.save(outboxPending, delivery)
+		if err := o.remove(outboxDead, delivery.ID); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// purge will delete the Delivery with ID id from the outbox (or every dead one if id is "all").
+// It returns the number deleted.
+func (o *Outbox) purge(id string) (int, error) {
+	count := 0
+	for _, state := range []string{outboxPending, outboxDead} {
+		deliveries, err := o.list(state)
+		if err != nil {
+			return count, err
+		}
+		for _, delivery := range deliveries {
+			if (id == "all" && state == outboxDead) || delivery.ID == id {
+				if err := o.remove(state, delivery.ID); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return count, err
+				}
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// outboxCLI will act on the '-outbox-*' flags and exit if any were given.
+func outboxCLI(list *bool, retry *string, purge *string) {
+	if !*list && *retry == "" && *purge == "" {
+		return
+	}
+	jLog.Fatal("The outbox is disabled ('-data' is blank)", outbox.dir == "")
+
+	if *retry != "" {
+		count, err := outbox.retryDead(*retry)
+		jLog.Fatal(fmt.Sprintf("Failed to retry '%s'\n%s", *retry, err), err != nil)
+		fmt.Printf("Moved %d dead deliveries back to pending. They'll be sent on the next check of the outbox.\n", count)
+	}
+	if *purge != "" {
+		count, err := outbox.purge(*purge)
+		jLog.Fatal(fmt.Sprintf("Failed to purge '%s'\n%s", *purge, err), err != nil)
+		fmt.Printf("Purged %d deliveries.\n", count)
+	}
+	if *list {
+		outbox.print()
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutbox(t *testing.T) {
+	config := Config{
+		Monitor: MonitorSlice{
+			{
+				ID:   "Monitor",
+				Exec: ExecSlice{{Command: "exit 1", Timeout: "5s", NotifyOptions: NotifyOptions{MaxTries: 1, SilentFails: "y"}}},
+			},
+		},
+	}
+	config.Monitor[0].initNotifiers()
+	var testOutbox Outbox
+	testOutbox.init(t.TempDir(), &config)
+
+	// A Delivery that fails max_tries times should end up dead.
+	delivery := newDelivery("exec", "Monitor", 0, "exit 1", "Service", []byte(`{}`), "0s")
+	testOutbox.save(outboxPending, delivery)
+	testOutbox.active[delivery.ID] = true
+	testOutbox.send(delivery)
+	dead, _ := testOutbox.list(outboxDead)
+	if len(dead) != 1 || dead[0].ID != delivery.ID || dead[0].Attempts != 1 {
+		t.Fatalf(`dead = %v, want match for %s after 1 attempt`, dead, delivery.ID)
+	}
+	if pending, _ := testOutbox.list(outboxPending); len(pending) != 0 {
+		t.Fatalf(`pending = %v, want match for []`, pending)
+	}
+
+	// Retrying should move it back to pending.
+	if count, err := testOutbox.retryDead("all"); count != 1 || err != nil {
+		t.Fatalf(`retryDead("all") = %d, %v - want match for 1, <nil>`, count, err)
+	}
+	pending, _ := testOutbox.list(outboxPending)
+	if len(pending) != 1 || pending[0].Attempts != 0 || pending[0].Scheduled.After(time.Now()) {
+		t.Fatalf(`pending = %v, want match for %s with 0 attempts`, pending, delivery.ID)
+	}
+
+	// Purging should delete it.
+	if count, err := testOutbox.purge(delivery.ID); count != 1 || err != nil {
+		t.Fatalf(`purge(%q) = %d, %v - want match for 1, <nil>`, delivery.ID, count, err)
+	}
+	if pending, _ := testOutbox.list(outboxPending); len(pending) != 0 {
+		t.Fatalf(`pending = %v, want match for []`, pending)
+	}
+}
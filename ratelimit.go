@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// limitMaxListed is the number of the messages held back by a rate limit to list in its summary.
+const limitMaxListed = 20
+
+// RateLimit is the most messages to send within a window.
+type RateLimit struct {
+	Max    uint   `yaml:"max"`    // Most messages to send within Window (0 = unlimited).
+	Window string `yaml:"window"` // default - 1h = The window to count the messages sent within.
+}
+
+// setDefaults sets undefined variables to their default.
+func (r *RateLimit) setDefaults(defaults *RateLimit) {
+	if defaults != nil {
+		r.Max = valueOrValueUInt(r.Max, defaults.Max)
+		r.Window = valueOrValueString(r.Window, defaults.Window)
+	}
+	r.Window = valueOrValueString(r.Window, "1h")
+}
+
+// checkValues will check that the variables are valid (target is where in the config it is).
+func (r *RateLimit) checkValues(target string) {
+	if r.Window == "" {
+		return
+	}
+	if _, err := time.ParseDuration(r.Window); err != nil {
+		msg := fmt.Sprintf("%s.window (%s) is invalid (Use 'AhBmCs' duration format)", target, r.Window)
+		jLog.Fatal(msg, true)
+	}
+}
+
+// print will print the RateLimit.
+func (r *RateLimit) print(prefix string) {
+	fmt.Printf("%srate_limit:\n", prefix)
+	fmt.Printf("%s  max: %d\n", prefix, r.Max)
+	fmt.Printf("%s  window: %s\n", prefix, r.Window)
+}
+
+// getWindow returns the Window of the RateLimit.
+func (r *RateLimit) getWindow() time.Duration {
+	window, _ := time.ParseDuration(r.Window)
+	return window
+}
+
+// Limiter holds back the messages to messengers that are over the global RateLimit or that of their channel,
+// and summarises them once there's room.
+type Limiter struct {
+	global   RateLimit                 // The RateLimit across every channel.
+	sent     map[string][]time.Time    // When the messages within the window were sent to each channel ("" = every channel).
+	overflow map[string]*limitOverflow // The messages held back for each channel.
+	mutex    sync.Mutex                // Lock for sent and overflow.
+}
+
+// limitOverflow are the messages held back by a rate limit for a channel.
+type limitOverflow struct {
+	monitor  *Monitor // The Monitor of notifier.
+	notifier Notifier // The Notifier to send the summary with.
+	messages []string // What was held back, e.g. "owner/repo 1.2.4".
+}
+
+// init will initialise the Limiter with the global RateLimit.
+func (l *Limiter) init(global RateLimit) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.global = global
+	l.sent = map[string][]time.Time{}
+	l.overflow = map[string]*limitOverflow{}
+}
+
+// prune will forget the sends to key before window (from now), returning how many are left.
+// The caller must hold the lock.
+func (l *Limiter) prune(key string, window time.Duration, now time.Time) int {
+	sent := l.sent[key]
+	for len(sent) != 0 && !sent[0].After(now.Add(-window)) {
+		sent = sent[1:]
+	}
+	l.sent[key] = sent
+	return len(sent)
+}
+
+// hasRoom returns whether another message can be sent to the channel key of notifier at now.
+// The caller must hold the lock.
+func (l *Limiter) hasRoom(key string, notifier Notifier, now time.Time) bool {
+	if l.global.Max != 0 && uint(l.prune("", l.global.getWindow(), now)) >= l.global.Max {
+		return false
+	}
+	limit := notifier.getOptions().RateLimit
+	return limit == nil || limit.Max == 0 || uint(l.prune(key, limit.getWindow(), now)) < limit.Max
+}
+
+// record will count a message sent to the channel key at now. The caller must hold the lock.
+func (l *Limiter) record(key string, now time.Time) {
+	l.sent[""] = append(l.sent[""], now)
+	l.sent[key] = append(l.sent[key], now)
+}
+
+// allow returns whether a message (described by what) can be sent to notifier now, counting it if so.
+//
+// Otherwise, it's held back to be summarised once there's room.
+func (l *Limiter) allow(monitor *Monitor, notifier Notifier, what string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.sent == nil {
+		return true
+	}
+
+	key := getChannelKey(notifier)
+	now := time.Now()
+	// Keep the order, so nothing new is sent before the summary of what's held back.
+	if l.overflow[key] == nil && l.hasRoom(key, notifier, now) {
+		l.record(key, now)
+		return true
+	}
+
+	msg := fmt.Sprintf("%s, not sending '%s' to %s as it's over the rate limit", monitor.ID, what, notifier.getTarget())
+	jLog.Warn(msg, true)
+	if l.overflow[key] == nil {
+		l.overflow[key] = &limitOverflow{monitor: monitor, notifier: notifier}
+	}
+	l.overflow[key].messages = append(l.overflow[key].messages, what)
+	return false
+}
+
+// flush will send the summary of the messages held back for each channel that has room at now.
+func (l *Limiter) flush(defaults Defaults, now time.Time) {
+	type summary struct {
+		overflow       *limitOverflow
+		title, message string
+	}
+	var summaries []summary
+
+	l.mutex.Lock()
+	for key, overflow := range l.overflow {
+		if !l.hasRoom(key, overflow.notifier, now) {
+			continue
+		}
+		l.record(key, now)
+		delete(l.overflow, key)
+		title, message := formatOverflow(overflow.messages)
+		summaries = append(summaries, summary{overflow: overflow, title: title, message: message})
+	}
+	l.mutex.Unlock()
+
+	for _, summary := range summaries {
+		event := &ReleaseEvent{MonitorID: summary.overflow.monitor.ID, ServiceID: "rate_limit"}
+		summary.overflow.monitor.enqueue(summary.overflow.notifier, event, summary.title, summary.message, defaults)
+	}
+}
+
+// formatOverflow returns the title and message of the summary of the messages held back by a rate limit.
+func formatOverflow(messages []string) (string, string) {
+	title := fmt.Sprintf("...and %d more", len(messages))
+	listed := messages
+	if len(listed) > limitMaxListed {
+		listed = listed[:limitMaxListed]
+	}
+	message := fmt.Sprintf("%s (held back by the rate limit):\n- %s", title, strings.Join(listed, "\n- "))
+	if len(messages) > len(listed) {
+		message += fmt.Sprintf("\n- ...and %d more", len(messages)-len(listed))
+	}
+	return title, message
+}
+
+// run will send the summaries of the messages held back when there's room (forever).
+func (l *Limiter) run(defaults Defaults) {
+	for {
+		l.flush(defaults, time.Now())
+		time.Sleep(time.Minute)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	outbox.init("", &Config{})
+	var testLimiter Limiter
+	testLimiter.init(RateLimit{Max: 3, Window: "1h"})
+	monitor := &Monitor{ID: "Monitor"}
+	team := &Slack{URL: "https://slack/team", NotifyOptions: NotifyOptions{Kind: "slack", RateLimit: &RateLimit{Max: 2, Window: "1h"}}}
+	ops := &Slack{URL: "https://slack/ops", NotifyOptions: NotifyOptions{Kind: "slack"}}
+
+	// 2 for the channel.
+	for i, want := range []bool{true, true, false, false} {
+		if got := testLimiter.allow(monitor, team, "owner/repo 1.2.3"); got != want {
+			t.Fatalf(`allow() of message %d to team = %t, want match for %t`, i+1, got, want)
+		}
+	}
+	// 3 globally.
+	for i, want := range []bool{true, false} {
+		if got := testLimiter.allow(monitor, ops, "owner/repo 1.2.3"); got != want {
+			t.Fatalf(`allow() of message %d to ops = %t, want match for %t`, i+1, got, want)
+		}
+	}
+	if got := len(testLimiter.overflow[getChannelKey(team)].messages); got != 2 {
+		t.Fatalf(`overflow of team = %d messages, want match for 2`, got)
+	}
+
+	// No room yet.
+	testLimiter.flush(Defaults{}, time.Now())
+	if len(testLimiter.overflow) != 2 {
+		t.Fatalf(`overflow = %d channels after flush(), want match for 2`, len(testLimiter.overflow))
+	}
+
+	// Room once the window has passed.
+	testLimiter.flush(Defaults{}, time.Now().Add(2*time.Hour))
+	if len(testLimiter.overflow) != 0 {
+		t.Fatalf(`overflow = %d channels after the window, want match for 0`, len(testLimiter.overflow))
+	}
+}
+
+func TestFormatOverflow(t *testing.T) {
+	var messages []string
+	for i := 0; i < limitMaxListed+5; i++ {
+		messages = append(messages, "owner/repo 1.2.3")
+	}
+
+	title, message := formatOverflow(messages)
+	if want := "...and 25 more"; title != want {
+		t.Fatalf(`formatOverflow() title = %q, want match for %q`, title, want)
+	}
+	if !strings.HasPrefix(message, title) || !strings.HasSuffix(message, "- ...and 5 more") || strings.Count(message, "owner/repo") != limitMaxListed {
+		t.Fatalf(`formatOverflow() message = %q, want match for %d listed and "...and 5 more"`, message, limitMaxListed)
+	}
+}
+
+func TestStateAnnounce(t *testing.T) {
+	dir := t.TempDir()
+	var testState State
+	testState.init(dir)
+	if testState.announced("slack URL", "owner/repo", "1.2.4") {
+		t.Fatalf(`announced() of a new version = true, want match for false`)
+	}
+	testState.announce("slack URL", "owner/repo", "1.2.4")
+
+	// Never twice to the same channel, even after a restart.
+	var restarted State
+	restarted.init(dir)
+	if !restarted.announced("slack URL", "owner/repo", "1.2.4") {
+		t.Fatalf(`announced() of an announced version = false, want match for true`)
+	}
+	if restarted.announced("gotify URL", "owner/repo", "1.2.4") {
+		t.Fatalf(`announced() to another channel = true, want match for false`)
+	}
+}
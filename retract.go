@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+)
+
+// retract will tell the messengers of this Monitor that svc doesn't skip that the release of event
+// was retracted, and send the rollback of it to the WebHooks with rollback enabled.
+func (m *Monitor) retract(svc *Service, event *ReleaseEvent, defaults Defaults) {
+	state.addRetraction(event)
+
+	title := fmt.Sprintf("%s %s retracted", event.ServiceID, event.Version)
+	message := fmt.Sprintf("%s %s was retracted. The latest release is now %s.", event.ServiceID, event.Version, event.RollbackTo)
+	for _, notifier := range m.notifiers {
+		if svc.skips(notifier) || m.mutes(notifier, event) {
+			continue
+		}
+		if notifierTypes[notifier.getOptions().Kind].messenger {
+			m.queue(notifier, event, title, message, defaults)
+		} else if webhook, ok := notifier.(*WebHook); ok && webhook.Rollback == "y" {
+			m.queue(notifier, event, "", "", defaults)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy is how to space out the retries of a failed send.
+type RetryPolicy struct {
+	InitialDelay string   `yaml:"initial_delay,omitempty"` // The delay before the first retry.
+	Multiplier   *float64 `yaml:"multiplier,omitempty"`    // The delay is multiplied by this after each retry (at least 1).
+	MaxDelay     string   `yaml:"max_delay,omitempty"`     // The most the delay can grow to.
+	Jitter       *float64 `yaml:"jitter,omitempty"`        // Randomise each delay by up to +/- this fraction of it (0.1 = 10%).
+	Timeout      string   `yaml:"timeout,omitempty"`       // The time to allow each try to take.
+}
+
+// setDefaults sets undefined variables to their default.
+func (r *RetryPolicy) setDefaults(defaults RetryPolicy) {
+	r.InitialDelay = valueOrValueString(r.InitialDelay, defaults.InitialDelay)
+	// Pointers, so that an explicit 0 isn't replaced by the default.
+	if r.Multiplier == nil {
+		r.Multiplier = defaults.Multiplier
+	}
+	r.MaxDelay = valueOrValueString(r.MaxDelay, defaults.MaxDelay)
+	if r.Jitter == nil {
+		r.Jitter = defaults.Jitter
+	}
+	r.Timeout = valueOrValueString(r.Timeout, defaults.Timeout)
+}
+
+// checkValues will check that the variables are valid for this RetryPolicy.
+func (r *RetryPolicy) checkValues(target string) {
+	durations := []struct {
+		name  string
+		value *string
+	}{
+		{"initial_delay", &r.InitialDelay},
+		{"max_delay", &r.MaxDelay},
+		{"timeout", &r.Timeout},
+	}
+	for _, duration := range durations {
+		if *duration.value == "" {
+			continue
+		}
+		// Default to seconds when an integer is provided
+		if _, err := strconv.Atoi(*duration.value); err == nil {
+			*duration.value += "s"
+		}
+		if _, err := time.ParseDuration(*duration.value); err != nil {
+			msg := fmt.Sprintf("%s.retry.%s (%s) is invalid (Use 'AhBmCs' duration format)", target, duration.name, *duration.value)
+			jLog.Fatal(msg, true)
+		}
+	}
+
+	if r.Multiplier != nil && *r.Multiplier < 1 {
+		msg := fmt.Sprintf("%s.retry.multiplier (%g) is invalid, it can't be less than 1", target, *r.Multiplier)
+		jLog.Fatal(msg, true)
+	}
+	if r.Jitter != nil && (*r.Jitter < 0 || *r.Jitter > 1) {
+		msg := fmt.Sprintf("%s.retry.jitter (%g) is invalid, it should be between 0 and 1", target, *r.Jitter)
+		jLog.Fatal(msg, true)
+	}
+}
+
+// print will print the RetryPolicy.
+func (r *RetryPolicy) print(prefix string) {
+	fmt.Printf("%sretry:\n", prefix)
+	fmt.Printf("%s  initial_delay: %s\n", prefix, r.InitialDelay)
+	if r.Multiplier != nil {
+		fmt.Printf("%s  multiplier: %g\n", prefix, *r.Multiplier)
+	}
+	fmt.Printf("%s  max_delay: %s\n", prefix, r.MaxDelay)
+	if r.Jitter != nil {
+		fmt.Printf("%s  jitter: %g\n", prefix, *r.Jitter)
+	}
+	fmt.Printf("%s  timeout: %s\n", prefix, r.Timeout)
+}
+
+// getTimeout returns the time to allow each try to take.
+func (r *RetryPolicy) getTimeout() time.Duration {
+	timeout, _ := time.ParseDuration(r.Timeout)
+	return timeout
+}
+
+// getDelay returns the delay before retry number 'retry' (starting at 1).
+func (r *RetryPolicy) getDelay(retry uint) time.Duration {
+	initialDelay, _ := time.ParseDuration(r.InitialDelay)
+	maxDelay, _ := time.ParseDuration(r.MaxDelay)
+
+	delay := float64(initialDelay)
+	if r.Multiplier != nil {
+		delay *= math.Pow(*r.Multiplier, float64(retry-1))
+	}
+	if maxDelay != 0 && delay > float64(maxDelay) {
+		delay = float64(maxDelay)
+	}
+	if r.Jitter != nil && *r.Jitter != 0 {
+		delay += delay * *r.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// next returns whether a send that failed with err on try number 'try' should be retried, and how long
+// to wait before that retry following the RetryPolicy (or the Retry-After of the failure).
+//
+// Permanent failures and failures after maxTries aren't retried.
+func (r *RetryPolicy) next(try uint, maxTries uint, err error) (bool, time.Duration) {
+	var sendErr *sendError
+	isSendError := errors.As(err, &sendErr)
+	if (isSendError && sendErr.permanent) || try >= maxTries {
+		return false, 0
+	}
+
+	delay := r.getDelay(try)
+	if isSendError && sendErr.retryAfter > delay {
+		delay = sendErr.retryAfter
+	}
+	return true, delay
+}
+
+// sendError is a failed send along with whether it's worth retrying.
+type sendError struct {
+	err        error         // What went wrong.
+	permanent  bool          // Whether retrying won't help (e.g. a 4XX).
+	retryAfter time.Duration // The Retry-After of the response.
+}
+
+// Error returns the error message of the sendError.
+func (e *sendError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error of the sendError.
+func (e *sendError) Unwrap() error {
+	return e.err
+}
+
+// newResponseError returns err as a sendError classified by the status code (and Retry-After) of resp.
+//
+// 408 (Request Timeout), 429 (Too Many Requests) and 5XX's are worth retrying, other 4XX's aren't.
+func newResponseError(resp *http.Response, err error) error {
+	retryAfter := getRetryAfter(resp.Header.Get("Retry-After"))
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return &sendError{err: err, retryAfter: retryAfter}
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return &sendError{err: err, permanent: true}
+	default:
+		return &sendError{err: err, retryAfter: retryAfter}
+	}
+}
+
+// getRetryAfter returns the duration of a Retry-After header in either
+// the delay-seconds or HTTP-date format (0 if invalid/blank).
+func getRetryAfter(retryAfter string) time.Duration {
+	if retryAfter == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(retryAfter); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
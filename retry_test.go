@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyNext(t *testing.T) {
+	policy := RetryPolicy{InitialDelay: "10s"}
+	retryable := &sendError{err: errors.New("503 Service Unavailable"), retryAfter: time.Minute}
+
+	// Retryable failures should be retried until maxTries, honouring Retry-After.
+	retry, delay := policy.next(1, 3, retryable)
+	if !retry || delay != time.Minute {
+		t.Fatalf(`next(1, 3) = %t, %s - want match for true, 1m0s`, retry, delay)
+	}
+	retry, _ = policy.next(3, 3, retryable)
+	if retry {
+		t.Fatalf(`next(3, 3) = %t, want match for false`, retry)
+	}
+
+	// Permanent failures shouldn't be retried.
+	retry, _ = policy.next(1, 3, &sendError{err: errors.New("404 Not Found"), permanent: true})
+	if retry {
+		t.Fatalf(`next(1, 3) of a permanent failure = %t, want match for false`, retry)
+	}
+
+	// Other errors (e.g. timeouts) should be retried with the policy's delay.
+	retry, delay = policy.next(1, 3, errors.New("context deadline exceeded"))
+	if !retry || delay != 10*time.Second {
+		t.Fatalf(`next(1, 3) = %t, %s - want match for true, 10s`, retry, delay)
+	}
+}
+
+func TestRetryPolicyGetDelay(t *testing.T) {
+	multiplier := 2.0
+	policy := RetryPolicy{InitialDelay: "10s", Multiplier: &multiplier, MaxDelay: "30s"}
+
+	wants := []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second}
+	for index, want := range wants {
+		if got := policy.getDelay(uint(index + 1)); got != want {
+			t.Fatalf(`getDelay(%d) = %s, want match for %s`, index+1, got, want)
+		}
+	}
+}
+
+func TestRetryPolicySetDefaults(t *testing.T) {
+	multiplier, jitter, noJitter := 2.0, 0.1, 0.0
+	policy := RetryPolicy{Jitter: &noJitter}
+	policy.setDefaults(RetryPolicy{Multiplier: &multiplier, Jitter: &jitter})
+
+	// An explicit 0 is kept.
+	if *policy.Multiplier != 2 || *policy.Jitter != 0 {
+		t.Fatalf(`setDefaults() = multiplier %g, jitter %g - want match for 2, 0`, *policy.Multiplier, *policy.Jitter)
+	}
+}
+
+func TestNewResponseError(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		retryAfter string
+		permanent  bool
+		wantAfter  time.Duration
+	}{
+		{statusCode: http.StatusTooManyRequests, retryAfter: "120", wantAfter: 2 * time.Minute},
+		{statusCode: http.StatusServiceUnavailable},
+		{statusCode: http.StatusRequestTimeout},
+		{statusCode: http.StatusNotFound, permanent: true},
+		{statusCode: http.StatusUnauthorized, permanent: true},
+	}
+
+	for _, test := range tests {
+		resp := &http.Response{StatusCode: test.statusCode, Header: http.Header{}}
+		resp.Header.Set("Retry-After", test.retryAfter)
+
+		var got *sendError
+		if !errors.As(newResponseError(resp, errors.New("fail")), &got) {
+			t.Fatalf(`newResponseError(%d) didn't return a sendError`, test.statusCode)
+		}
+		if got.permanent != test.permanent {
+			t.Fatalf(`newResponseError(%d).permanent = %t, want match for %t`, test.statusCode, got.permanent, test.permanent)
+		}
+		if got.retryAfter != test.wantAfter {
+			t.Fatalf(`newResponseError(%d).retryAfter = %s, want match for %s`, test.statusCode, got.retryAfter, test.wantAfter)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed cron expression.
+//
+// Each field is a bitset of the values it matches.
+type cronSchedule struct {
+	second     uint64         // 0-59
+	minute     uint64         // 0-59
+	hour       uint64         // 0-23
+	dayOfMonth uint64         // 1-31
+	month      uint64         // 1-12
+	dayOfWeek  uint64         // 0-6 (Sunday = 0)
+	anyDay     bool           // Whether either day field is '*' (so only the other one has to match).
+	location   *time.Location // The timezone the expression is in.
+}
+
+// cronField is the range (and names) of a field of a cron expression.
+type cronField struct {
+	name  string
+	min   uint
+	max   uint
+	names map[string]uint
+}
+
+var (
+	cronSecond     = cronField{name: "second", min: 0, max: 59}
+	cronMinute     = cronField{name: "minute", min: 0, max: 59}
+	cronHour       = cronField{name: "hour", min: 0, max: 23}
+	cronDayOfMonth = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth      = cronField{name: "month", min: 1, max: 12, names: map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is also Sunday.
+	cronDayOfWeek = cronField{name: "day of week", min: 0, max: 7, names: map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// cronMacros are the shorthands for common expressions.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// parseCron returns the cronSchedule of spec in location.
+//
+// spec is "[SECOND] MINUTE HOUR DAY_OF_MONTH MONTH DAY_OF_WEEK" (or a macro like "@hourly"),
+// optionally starting with "CRON_TZ=Europe/London " to give the timezone.
+// Each field can be '*', '?', a value, a range ('1-5'), a step ('*/15', '0-30/10')
+// or a list of those ('1,15,30'). Months and days of the week can be names ('jan', 'mon-fri').
+func parseCron(spec string, location *time.Location) (*cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		parts := strings.SplitN(spec, " ", 2)
+		var err error
+		location, err = time.LoadLocation(strings.SplitN(parts[0], "=", 2)[1])
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) == 1 {
+			return nil, fmt.Errorf("no expression after %q", parts[0])
+		}
+		spec = strings.TrimSpace(parts[1])
+	}
+	if macro, exists := cronMacros[strings.ToLower(spec)]; exists {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("%q has %d fields, want 5 or 6 ([SECOND] MINUTE HOUR DAY_OF_MONTH MONTH DAY_OF_WEEK)", spec, len(fields))
+	}
+
+	schedule := &cronSchedule{location: location}
+	var err error
+	for index, field := range []struct {
+		bits *uint64
+		cronField
+	}{
+		{&schedule.second, cronSecond},
+		{&schedule.minute, cronMinute},
+		{&schedule.hour, cronHour},
+		{&schedule.dayOfMonth, cronDayOfMonth},
+		{&schedule.month, cronMonth},
+		{&schedule.dayOfWeek, cronDayOfWeek},
+	} {
+		if *field.bits, err = field.parse(fields[index]); err != nil {
+			return nil, err
+		}
+	}
+	// Sunday can be 0 or 7.
+	if schedule.dayOfWeek&(1<<7) != 0 {
+		schedule.dayOfWeek = schedule.dayOfWeek&^(1<<7) | 1
+	}
+	schedule.anyDay = isCronWildcard(fields[3]) || isCronWildcard(fields[5])
+	return schedule, nil
+}
+
+// isCronWildcard returns whether field matches every value.
+func isCronWildcard(field string) bool {
+	return field == "*" || field == "?"
+}
+
+// parse returns the bitset of the values that text matches for this field.
+func (f cronField) parse(text string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(strings.ToLower(text), ",") {
+		rangeText, step := part, uint(1)
+		if slash := strings.Index(part, "/"); slash != -1 {
+			parsedStep, err := strconv.ParseUint(part[slash+1:], 10, 8)
+			if err != nil || parsedStep == 0 {
+				return 0, fmt.Errorf("%s step %q is invalid", f.name, part[slash+1:])
+			}
+			rangeText, step = part[:slash], uint(parsedStep)
+		}
+
+		var start, end uint
+		switch {
+		case isCronWildcard(rangeText):
+			start, end = f.min, f.max
+			if f.max == 7 {
+				end = 6
+			}
+		case strings.Contains(rangeText, "-"):
+			bounds := strings.SplitN(rangeText, "-", 2)
+			var err error
+			if start, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if end, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			// 'sat-sun' ends on Sunday as 7.
+			if f.max == 7 && end == 0 && start > 0 {
+				end = 7
+			}
+			if end < start {
+				return 0, fmt.Errorf("%s range %q is backwards", f.name, rangeText)
+			}
+		default:
+			var err error
+			if start, err = f.value(rangeText); err != nil {
+				return 0, err
+			}
+			end = start
+			// 'a/n' is 'a-max/n'.
+			if step != 1 {
+				end = f.max
+			}
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+// value returns the value of text (a number or name) for this field.
+func (f cronField) value(text string) (uint, error) {
+	if value, exists := f.names[text]; exists {
+		return value, nil
+	}
+	value, err := strconv.ParseUint(text, 10, 8)
+	if err != nil || uint(value) < f.min || uint(value) > f.max {
+		return 0, fmt.Errorf("%s %q is invalid (%d-%d)", f.name, text, f.min, f.max)
+	}
+	return uint(value), nil
+}
+
+// next returns the first time after t that matches the schedule (the zero time if there isn't one within 5 years).
+func (c *cronSchedule) next(t time.Time) time.Time {
+	t = t.In(c.location).Truncate(time.Second).Add(time.Second)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.location)
+			continue
+		}
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.location)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.location)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Truncate(time.Minute).Add(time.Minute)
+			continue
+		}
+		if c.second&(1<<uint(t.Second())) == 0 {
+			t = t.Add(time.Second)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// matchesDay returns whether the day of t matches the day of month and day of week fields.
+//
+// Like cron, when both are restricted, matching either is enough.
+func (c *cronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := c.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := c.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if c.anyDay {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// Calendar is when a Service may be queried (e.g. business hours).
+type Calendar struct {
+	Days         string          `yaml:"days"`          // default - "mon-sun" = The days of the week, e.g. "mon-fri" or "mon,wed,fri".
+	Hours        string          `yaml:"hours"`         // default - "00:00-24:00" = The times of day, e.g. "09:00-17:30" or "09:00-12:00,13:00-17:00".
+	ExcludeDates []string        `yaml:"exclude_dates"` // Dates to never query on, e.g. "2022-12-25".
+	days         uint64          ``                     // Bitset of the days of the week.
+	windows      [][2]int        ``                     // The start and end minutes of the day of each window of Hours.
+	excluded     map[string]bool ``                     // The ExcludeDates.
+}
+
+// parse will parse the Days, Hours and ExcludeDates of the Calendar.
+func (c *Calendar) parse() error {
+	var err error
+	if c.days, err = cronDayOfWeek.parse(valueOrValueString(c.Days, "*")); err != nil {
+		return err
+	}
+	if c.days&(1<<7) != 0 {
+		c.days = c.days&^(1<<7) | 1
+	}
+
+	c.windows = nil
+	for _, window := range strings.Split(valueOrValueString(c.Hours, "00:00-24:00"), ",") {
+		bounds := strings.Split(strings.TrimSpace(window), "-")
+		if len(bounds) != 2 {
+			return fmt.Errorf("hours %q is invalid, want 'HH:MM-HH:MM'", window)
+		}
+		var minutes [2]int
+		for index, bound := range bounds {
+			clock, err := time.Parse("15:04", bound)
+			switch {
+			case bound == "24:00":
+				minutes[index] = 24 * 60
+			case err != nil:
+				return fmt.Errorf("hours %q is invalid, want 'HH:MM-HH:MM'", window)
+			default:
+				minutes[index] = clock.Hour()*60 + clock.Minute()
+			}
+		}
+		if minutes[1] <= minutes[0] {
+			return fmt.Errorf("hours %q ends before it starts", window)
+		}
+		c.windows = append(c.windows, minutes)
+	}
+
+	c.excluded = map[string]bool{}
+	for _, date := range c.ExcludeDates {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("exclude_dates %q is invalid, want 'YYYY-MM-DD'", date)
+		}
+		c.excluded[date] = true
+	}
+	return nil
+}
+
+// contains returns whether t is within the Calendar (in location).
+func (c *Calendar) contains(t time.Time, location *time.Location) bool {
+	t = t.In(location)
+	if c.days&(1<<uint(t.Weekday())) == 0 || c.excluded[t.Format("2006-01-02")] {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	for _, window := range c.windows {
+		if minute >= window[0] && minute < window[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// nextOpen returns t if it's within the Calendar, otherwise the start of its next window after t
+// (the zero time if there isn't one within a year).
+func (c *Calendar) nextOpen(t time.Time, location *time.Location) time.Time {
+	if c.contains(t, location) {
+		return t
+	}
+	t = t.In(location)
+	for day := 0; day <= 366; day++ {
+		date := time.Date(t.Year(), t.Month(), t.Day()+day, 0, 0, 0, 0, location)
+		if c.days&(1<<uint(date.Weekday())) == 0 || c.excluded[date.Format("2006-01-02")] {
+			continue
+		}
+		for _, window := range c.windows {
+			start := date.Add(time.Duration(window[0]) * time.Minute)
+			if start.After(t) {
+				return start
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// print will print the Calendar (as key).
+func (c *Calendar) print(prefix string, key string) {
+	fmt.Printf("%s%s:\n", prefix, key)
+	fmt.Printf("%s  days: %s\n", prefix, valueOrValueString(c.Days, "mon-sun"))
+	fmt.Printf("%s  hours: %s\n", prefix, valueOrValueString(c.Hours, "00:00-24:00"))
+	if len(c.ExcludeDates) != 0 {
+		fmt.Printf("%s  exclude_dates: [%s]\n", prefix, strings.Join(c.ExcludeDates, ", "))
+	}
+}
+
+// nextQuery returns when this Service should next be queried after a query at t
+// (its schedule, or its interval, within its calendar), backing off when queries are failing.
+func (s *Service) nextQuery(t time.Time) time.Time {
+	location := s.location
+	if location == nil {
+		location = time.Local
+	}
+
+	var next time.Time
+	if s.schedule != nil {
+		// Skip the times on the schedule within the back off (when queries are failing).
+		from := t
+		if s.Adaptive == "y" && s.status.getFailures() != 0 {
+			minInterval, _ := time.ParseDuration(s.MinInterval)
+			from = t.Add(s.backoff(minInterval))
+		}
+		next = s.schedule.next(from)
+		// The next time on the schedule that's within the calendar.
+		for s.Calendar != nil && !next.IsZero() && !s.Calendar.contains(next, location) {
+			open := s.Calendar.nextOpen(next, location)
+			if open.IsZero() {
+				next = open
+				break
+			}
+			next = s.schedule.next(open.Add(-time.Second))
+		}
+	} else {
+		next = t.Add(s.backoff(s.getInterval()))
+		if s.Calendar != nil {
+			next = s.Calendar.nextOpen(next, location)
+		}
+	}
+
+	if next.IsZero() {
+		msg := fmt.Sprintf("%s, no time to query found on the schedule/calendar within a year, so querying again in a year", s.ID)
+		jLog.Warn(msg, true)
+		next = t.AddDate(1, 0, 0)
+	}
+	return next
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	invalid := []string{
+		"* * * *",                   // Too few fields.
+		"60 * * * * *",              // Second out of range.
+		"* * 5-1 * *",               // Backwards range.
+		"*/0 * * * *",               // Zero step.
+		"* * * * foo",               // Unknown day.
+		"CRON_TZ=Nowhere * * * * *", // Unknown timezone.
+	}
+	for _, spec := range invalid {
+		if _, err := parseCron(spec, time.UTC); err == nil {
+			t.Fatalf(`parseCron(%q) = <nil>, want match for an error`, spec)
+		}
+	}
+
+	schedule, err := parseCron("0 */15 9-17 * * mon-fri", time.UTC)
+	if err != nil {
+		t.Fatalf(`parseCron() = %v, want match for <nil>`, err)
+	}
+	if schedule.minute != 1|1<<15|1<<30|1<<45 || schedule.dayOfWeek != 0b0111110 {
+		t.Fatalf(`parseCron() = %+v, want match for minutes 0,15,30,45 on mon-fri`, schedule)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	tests := []struct {
+		spec string
+		from string
+		want string
+	}{
+		// Every hour of the working day (5 fields = no seconds).
+		{"0 9-17 * * mon-fri", "2022-06-03T17:30:00Z", "2022-06-06T09:00:00Z"}, // Friday evening -> Monday morning.
+		{"0 9-17 * * mon-fri", "2022-06-06T09:00:00Z", "2022-06-06T10:00:00Z"},
+		// Seconds.
+		{"*/20 * * * * *", "2022-06-06T09:00:05Z", "2022-06-06T09:00:20Z"},
+		// Names and steps.
+		{"0 0 0 1 jan,jul *", "2022-02-01T00:00:00Z", "2022-07-01T00:00:00Z"},
+		// Day of month or day of week when both are restricted.
+		{"0 0 0 13 * fri", "2022-06-01T00:00:00Z", "2022-06-03T00:00:00Z"},
+		{"@monthly", "2022-12-15T00:00:00Z", "2023-01-01T00:00:00Z"},
+		// 31st only in months that have one.
+		{"0 0 0 31 * *", "2022-04-01T00:00:00Z", "2022-05-31T00:00:00Z"},
+		// Sunday as 7.
+		{"0 0 12 * * 7", "2022-06-06T00:00:00Z", "2022-06-12T12:00:00Z"},
+		{"0 0 12 * * sat-sun", "2022-06-06T00:00:00Z", "2022-06-11T12:00:00Z"},
+		// Timezone (London is UTC+1 in June).
+		{"CRON_TZ=Europe/London 0 0 9 * * *", "2022-06-06T09:00:00Z", "2022-06-07T08:00:00Z"},
+	}
+
+	for _, test := range tests {
+		schedule, err := parseCron(test.spec, time.UTC)
+		if err != nil {
+			t.Fatalf(`parseCron(%q) = %v, want match for <nil>`, test.spec, err)
+		}
+		from, _ := time.Parse(time.RFC3339, test.from)
+		want, _ := time.Parse(time.RFC3339, test.want)
+		if got := schedule.next(from); !got.Equal(want) {
+			t.Fatalf(`%q next(%s) = %s, want match for %s`, test.spec, test.from, got.UTC().Format(time.RFC3339), test.want)
+		}
+	}
+
+	// The location of the Service is used without CRON_TZ.
+	schedule, _ := parseCron("0 0 9 * * *", london)
+	from, _ := time.Parse(time.RFC3339, "2022-01-10T10:00:00Z")
+	if got := schedule.next(from); got.UTC().Format(time.RFC3339) != "2022-01-11T09:00:00Z" {
+		t.Fatalf(`next() = %s, want match for 2022-01-11T09:00:00Z`, got.UTC().Format(time.RFC3339))
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	calendar := Calendar{Days: "mon-fri", Hours: "09:00-12:00,13:00-17:30", ExcludeDates: []string{"2022-06-06"}}
+	if err := calendar.parse(); err != nil {
+		t.Fatalf(`parse() = %v, want match for <nil>`, err)
+	}
+	tests := []struct {
+		at       string
+		contains bool
+		nextOpen string
+	}{
+		{"2022-06-07T10:00:00Z", true, "2022-06-07T10:00:00Z"},
+		{"2022-06-07T12:30:00Z", false, "2022-06-07T13:00:00Z"}, // Lunch.
+		{"2022-06-07T17:30:00Z", false, "2022-06-08T09:00:00Z"}, // Evening.
+		{"2022-06-03T18:00:00Z", false, "2022-06-07T09:00:00Z"}, // Friday -> Tuesday (Monday is excluded).
+	}
+	for _, test := range tests {
+		at, _ := time.Parse(time.RFC3339, test.at)
+		if got := calendar.contains(at, time.UTC); got != test.contains {
+			t.Fatalf(`contains(%s) = %t, want match for %t`, test.at, got, test.contains)
+		}
+		if got := calendar.nextOpen(at, time.UTC).Format(time.RFC3339); got != test.nextOpen {
+			t.Fatalf(`nextOpen(%s) = %s, want match for %s`, test.at, got, test.nextOpen)
+		}
+	}
+
+	for _, invalid := range []Calendar{{Hours: "17:00-09:00"}, {Hours: "9am-5pm"}, {Days: "someday"}, {ExcludeDates: []string{"25/12/2022"}}} {
+		if err := invalid.parse(); err == nil {
+			t.Fatalf(`%+v parse() = <nil>, want match for an error`, invalid)
+		}
+	}
+}
+
+func TestServiceNextQuery(t *testing.T) {
+	calendar := &Calendar{Days: "mon-fri", Hours: "09:00-17:00"}
+	calendar.parse()
+	schedule, _ := parseCron("0 0 * * * *", time.UTC)
+	from, _ := time.Parse(time.RFC3339, "2022-06-03T16:30:00Z") // Friday.
+
+	// Interval within the calendar.
+	svc := Service{Interval: "1h", Calendar: calendar, location: time.UTC, status: newStatus()}
+	if got := svc.nextQuery(from).Format(time.RFC3339); got != "2022-06-06T09:00:00Z" {
+		t.Fatalf(`nextQuery() = %s, want match for 2022-06-06T09:00:00Z`, got)
+	}
+
+	// Schedule within the calendar.
+	svc = Service{Schedule: "0 0 * * * *", schedule: schedule, Calendar: calendar, location: time.UTC, status: newStatus()}
+	if got := svc.nextQuery(from).Format(time.RFC3339); got != "2022-06-06T09:00:00Z" {
+		t.Fatalf(`nextQuery() = %s, want match for 2022-06-06T09:00:00Z`, got)
+	}
+	from, _ = time.Parse(time.RFC3339, "2022-06-03T15:30:00Z")
+	if got := svc.nextQuery(from).Format(time.RFC3339); got != "2022-06-03T16:00:00Z" {
+		t.Fatalf(`nextQuery() = %s, want match for 2022-06-03T16:00:00Z`, got)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Scheduler runs the queries of every Service on a pool of workers, each when it's next due.
+type Scheduler struct {
+	Concurrency uint   `yaml:"concurrency"` // default - 10 = Number of queries to run at once.
+	PerHost     uint   `yaml:"per_host"`    // default - 2 = Number of queries to run at once against each host.
+	Jitter      string `yaml:"jitter"`      // default - 30s = The first query of each Service is at a random time within this from startup.
+
+	queue   scheduleQueue                // The queries waiting for their next run, soonest first.
+	waiting map[string][]*scheduledQuery // The queries that are due but waiting for a query of their host to finish.
+	running map[string]uint              // Number of queries running against each host.
+	wake    chan struct{}                // Signal that the queue has changed.
+	mutex   sync.Mutex                   // Lock for queue, waiting and running.
+	check   func(query *scheduledQuery)  // What to run for each query (Monitor.check).
+}
+
+// scheduledQuery is a Service in the Scheduler.
+type scheduledQuery struct {
+	monitor      *Monitor  // The Monitor the Service is in.
+	serviceIndex int       // Index of the Service in the Monitor.
+	host         string    // Host of the Service URL (for Scheduler.PerHost).
+	next         time.Time // When to run the query next.
+	index        int       // Index in the scheduleQueue.
+}
+
+// scheduleQueue is a min-heap of scheduledQuery's by next run.
+type scheduleQueue []*scheduledQuery
+
+func (q scheduleQueue) Len() int           { return len(q) }
+func (q scheduleQueue) Less(i, j int) bool { return q[i].next.Before(q[j].next) }
+func (q scheduleQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+// Push adds x to the queue (use heap.Push).
+func (q *scheduleQueue) Push(x interface{}) {
+	query := x.(*scheduledQuery)
+	query.index = len(*q)
+	*q = append(*q, query)
+}
+
+// Pop removes the last of the queue (use heap.Pop).
+func (q *scheduleQueue) Pop() interface{} {
+	old := *q
+	query := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return query
+}
+
+// setDefaults sets undefined variables to their default.
+func (s *Scheduler) setDefaults() {
+	s.Concurrency = valueOrValueUInt(s.Concurrency, 10)
+	s.PerHost = valueOrValueUInt(s.PerHost, 2)
+	s.Jitter = valueOrValueString(s.Jitter, "30s")
+	s.checkValues()
+}
+
+// checkValues will check that the variables are valid.
+func (s *Scheduler) checkValues() {
+	// Default to seconds when an integer is provided
+	if _, err := strconv.Atoi(s.Jitter); err == nil {
+		s.Jitter += "s"
+	}
+	if _, err := time.ParseDuration(s.Jitter); err != nil {
+		msg := fmt.Sprintf("scheduler.jitter (%s) is invalid (Use 'AhBmCs' duration format)", s.Jitter)
+		jLog.Fatal(msg, true)
+	}
+}
+
+// print will print the Scheduler.
+func (s *Scheduler) print() {
+	fmt.Println("scheduler:")
+	fmt.Printf("  concurrency: %d\n", s.Concurrency)
+	fmt.Printf("  per_host: %d\n", s.PerHost)
+	fmt.Printf("  jitter: %s\n", s.Jitter)
+}
+
+// init will initialise the Scheduler with every Service of monitors, each first due at a random time within Jitter.
+func (s *Scheduler) init(monitors MonitorSlice, defaults Defaults) {
+	s.queue = scheduleQueue{}
+	s.waiting = map[string][]*scheduledQuery{}
+	s.running = map[string]uint{}
+	s.wake = make(chan struct{}, 1)
+	if s.check == nil {
+		s.check = func(query *scheduledQuery) {
+			query.monitor.check(query.serviceIndex, defaults)
+		}
+	}
+
+	jitter, _ := time.ParseDuration(s.Jitter)
+	now := time.Now()
+	for monitorIndex := range monitors {
+		monitor := &monitors[monitorIndex]
+		for serviceIndex := range monitor.Service {
+			service := &monitor.Service[serviceIndex]
+			when := "every " + service.Interval
+			if service.Schedule != "" {
+				when = fmt.Sprintf("on '%s'", service.Schedule)
+			}
+			msg := fmt.Sprintf("Tracking %s at %s %s", service.ID, service.URL, when)
+			jLog.Verbose(msg, true)
+
+			host := service.URL
+			if parsedURL, err := url.Parse(service.URL); err == nil && parsedURL.Host != "" {
+				host = parsedURL.Host
+			}
+			next := now
+			if jitter > 0 {
+				next = now.Add(time.Duration(rand.Int63n(int64(jitter))))
+			}
+			heap.Push(&s.queue, &scheduledQuery{monitor: monitor, serviceIndex: serviceIndex, host: host, next: next})
+		}
+	}
+}
+
+// run will run each query when it's due on Concurrency workers (forever).
+func (s *Scheduler) run() {
+	jobs := make(chan *scheduledQuery)
+	for worker := uint(0); worker < s.Concurrency; worker++ {
+		go s.work(jobs)
+	}
+
+	for {
+		s.mutex.Lock()
+		if len(s.queue) == 0 {
+			s.mutex.Unlock()
+			<-s.wake
+			continue
+		}
+		if wait := time.Until(s.queue[0].next); wait > 0 {
+			s.mutex.Unlock()
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-s.wake:
+				timer.Stop()
+			}
+			continue
+		}
+
+		query := heap.Pop(&s.queue).(*scheduledQuery)
+		// Wait for a query of this host to finish.
+		if s.running[query.host] >= s.PerHost {
+			s.waiting[query.host] = append(s.waiting[query.host], query)
+			s.mutex.Unlock()
+			continue
+		}
+		s.running[query.host]++
+		s.mutex.Unlock()
+
+		jobs <- query
+	}
+}
+
+// work will run the queries from jobs, and then queue their next run.
+func (s *Scheduler) work(jobs <-chan *scheduledQuery) {
+	for query := range jobs {
+		s.check(query)
+		svc := &query.monitor.Service[query.serviceIndex]
+		query.next = svc.nextQuery(time.Now())
+
+		s.mutex.Lock()
+		s.running[query.host]--
+		// Let the next query waiting on this host run.
+		if waiting := s.waiting[query.host]; len(waiting) != 0 {
+			heap.Push(&s.queue, waiting[0])
+			s.waiting[query.host] = waiting[1:]
+		}
+		heap.Push(&s.queue, query)
+		s.mutex.Unlock()
+
+		// Wake run() as the queue changed.
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"container/heap"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduleQueue(t *testing.T) {
+	now := time.Now()
+	queue := scheduleQueue{}
+	for _, offset := range []int{30, 10, 20, 0} {
+		heap.Push(&queue, &scheduledQuery{serviceIndex: offset, next: now.Add(time.Duration(offset) * time.Second)})
+	}
+
+	for _, want := range []int{0, 10, 20, 30} {
+		if got := heap.Pop(&queue).(*scheduledQuery).serviceIndex; got != want {
+			t.Fatalf(`heap.Pop(scheduleQueue) = %d, want match for %d`, got, want)
+		}
+	}
+}
+
+func TestSchedulerLimits(t *testing.T) {
+	// 6 Services on host a and 2 on host b.
+	monitors := MonitorSlice{
+		{ID: "a", Service: ServiceSlice{}},
+		{ID: "b", Service: ServiceSlice{}},
+	}
+	for i := 0; i < 6; i++ {
+		monitors[0].Service = append(monitors[0].Service, Service{ID: "a", URL: "https://a.example.com/release", Interval: "1h", status: newStatus()})
+	}
+	for i := 0; i < 2; i++ {
+		monitors[1].Service = append(monitors[1].Service, Service{ID: "b", URL: "https://b.example.com/release", Interval: "1h", status: newStatus()})
+	}
+
+	var (
+		mutex   sync.Mutex
+		running = map[string]int{}
+		most    = map[string]int{}
+		total   int
+		done    = make(chan struct{}, 8)
+	)
+	scheduler := Scheduler{Concurrency: 3, PerHost: 2, Jitter: "0s"}
+	scheduler.check = func(query *scheduledQuery) {
+		mutex.Lock()
+		running[query.host]++
+		total++
+		if running[query.host] > most[query.host] {
+			most[query.host] = running[query.host]
+		}
+		if total > most["all"] {
+			most["all"] = total
+		}
+		mutex.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mutex.Lock()
+		running[query.host]--
+		total--
+		mutex.Unlock()
+		done <- struct{}{}
+	}
+	scheduler.init(monitors, Defaults{})
+	go scheduler.run()
+
+	for i := 0; i < 8; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf(`Scheduler ran %d queries, want match for 8`, i)
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if most["a.example.com"] > 2 || most["b.example.com"] > 2 {
+		t.Fatalf(`Scheduler ran %v queries at once per host, want match for <= 2`, most)
+	}
+	if most["all"] > 3 {
+		t.Fatalf(`Scheduler ran %d queries at once, want match for <= 3`, most["all"])
+	}
+
+	// Every query is due again after the interval.
+	for i := 0; i < 100; i++ {
+		scheduler.mutex.Lock()
+		queued := len(scheduler.queue)
+		scheduler.mutex.Unlock()
+		if queued == 8 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	if len(scheduler.queue) != 8 || time.Until(scheduler.queue[0].next) < 59*time.Minute {
+		t.Fatalf(`Scheduler queue = %d queries (soonest in %s), want match for 8 in 1h`, len(scheduler.queue), time.Until(scheduler.queue[0].next))
+	}
+}
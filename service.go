@@ -2,6 +2,7 @@ package main
 
 import (
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -9,6 +10,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/coreos/go-semver/semver"
@@ -25,18 +27,37 @@ type Service struct {
 	URL                   string          `yaml:"url"`                    // type:URL - "https://example.com", type:github - "owner/repo" or "https://github.com/owner/repo".
 	URLCommands           URLCommandSlice `yaml:"url_commands"`           // Commands to filter the release from the URL request.
 	Interval              string          `yaml:"interval"`               // AhBmCs = Sleep A hours, B minutes and C seconds between queries.
+	Adaptive              string          `yaml:"adaptive"`               // default - false = Derive the interval from how often the Service releases (within MinInterval and MaxInterval).
+	MinInterval           string          `yaml:"min_interval"`           // default - 5m = Shortest interval to use with Adaptive.
+	MaxInterval           string          `yaml:"max_interval"`           // default - 24h = Longest interval to use with Adaptive (and to back off to when queries fail).
+	Schedule              string          `yaml:"schedule"`               // Cron expression of when to query (instead of Interval), e.g. "0 0 9-17 * * mon-fri".
+	Timezone              string          `yaml:"timezone"`               // default - Local = Timezone of the Schedule and Calendar, e.g. "Europe/London".
+	Calendar              *Calendar       `yaml:"calendar"`               // When the Service may be queried (e.g. business hours).
 	ProgressiveVersioning string          `yaml:"progressive_versioning"` // default - true  = Version has to be greater than the previous to trigger Slack(s)/WebHook(s).
 	RegexContent          string          `yaml:"regex_content"`          // "abc-[a-z]+-${version}_amd64.deb" This regex must exist in the body of the URL to trigger new version actions.
 	RegexVersion          string          `yaml:"regex_version"`          // "v*[0-9.]+" The version found must match this release to trigger new version actions.
+	Changelog             string          `yaml:"changelog"`              // URL of the changelog to take the release notes from (or its path in the repo for type:github).
+	Compare               string          `yaml:"compare"`                // default - false = Don't summarise the commits/PRs between the old and new versions (type:github).
+	CompareMaxPRs         uint            `yaml:"compare_max_prs"`        // default - 10 = Number of merged PR titles to include in the summary.
+	OSV                   OSV             `yaml:"osv"`                    // Where to look up the known vulnerabilities of the deployed version.
+	IgnoreVersions        []string        `yaml:"ignore_versions"`        // Versions (exact, or regex of the whole version) to never move to, e.g. "3.0.0" or "3\\.0\\..*".
+	Confirmations         uint            `yaml:"confirmations"`          // default - 1 = Number of queries in a row a new version has to be found on before it counts.
+	MinReleaseAge         string          `yaml:"min_release_age"`        // How long a release has to stay the latest before WebHooks/Execs are sent (e.g. "24h").
+	Assets                Assets          `yaml:"assets"`                 // The assets a release must have (and be verified with) before it's notified (type:github).
+	Signature             Signature       `yaml:"signature"`              // How to verify that a release is signed by a pinned key (type:github).
+	SkipExec              bool            `yaml:"skip_exec"`              // default - false = Don't skip running commands for new releases.
 	SkipGotify            bool            `yaml:"skip_gotify"`            // default - false = Don't skip Gotify messages for new releases.
 	SkipSlack             bool            `yaml:"skip_slack"`             // default - false = Don't skip Slack messages for new releases.
 	SkipWebHook           bool            `yaml:"skip_webhook"`           // default - false = Don't skip WebHooks for new releases.
+	Skip                  []string        `yaml:"skip"`                   // Notifier kinds ("slack") and/or IDs to not send to for new releases.
 	IgnoreMiss            string          `yaml:"ignore_misses"`          // Ignore URLCommands that fail (e.g. split on text that doesn't exist)
 	AccessToken           string          `yaml:"access_token"`           // GitHub access token to use.
 	AllowInvalidCerts     string          `yaml:"allow_invalid"`          // default - false = Disallows invalid HTTPS certificates.
 	Gotify                Gotify          `yaml:"gotify"`                 // Override Gotify message vars.
 	Slack                 Slack           `yaml:"slack"`                  // Override Slack message vars.
-	status                status          ``                              // Track the Status of this source (version and regex misses).
+	status                *status         ``                              // Track the Status of this source (version and regex misses).
+	schedule              *cronSchedule   ``                              // The parsed Schedule.
+	location              *time.Location  ``                              // The parsed Timezone.
 }
 
 // UnmarshalYAML allows handling of a dict as well as a list of dicts.
@@ -306,6 +327,51 @@ func (s *Service) checkValues(monitorID string, index int, loneService bool) {
 		}
 	}
 
+	// MinInterval/MaxInterval
+	for name, interval := range map[string]*string{"min_interval": &s.MinInterval, "max_interval": &s.MaxInterval} {
+		if *interval == "" {
+			continue
+		}
+		// Default to seconds when an integer is provided
+		if _, err := strconv.Atoi(*interval); err == nil {
+			*interval += "s"
+		}
+		if _, err := time.ParseDuration(*interval); err != nil {
+			msg := fmt.Sprintf("%s.%s (%s) is invalid (Use 'AhBmCs' duration format)", target, name, *interval)
+			jLog.Fatal(msg, true)
+		}
+	}
+
+	// Timezone
+	s.location = time.Local
+	if s.Timezone != "" {
+		location, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			msg := fmt.Sprintf("%s.timezone (%s) is invalid\n%s", target, s.Timezone, err)
+			jLog.Fatal(msg, true)
+		}
+		s.location = location
+	}
+
+	// Schedule
+	s.schedule = nil
+	if s.Schedule != "" {
+		schedule, err := parseCron(s.Schedule, s.location)
+		if err != nil {
+			msg := fmt.Sprintf("%s.schedule (%s) is invalid\n%s", target, s.Schedule, err)
+			jLog.Fatal(msg, true)
+		}
+		s.schedule = schedule
+	}
+
+	// Calendar
+	if s.Calendar != nil {
+		if err := s.Calendar.parse(); err != nil {
+			msg := fmt.Sprintf("%s.calendar is invalid\n%s", target, err)
+			jLog.Fatal(msg, true)
+		}
+	}
+
 	// Slack - Delay
 	if s.Slack.Delay != "" {
 		if _, err := time.ParseDuration(s.Slack.Delay); err != nil {
@@ -313,19 +379,205 @@ func (s *Service) checkValues(monitorID string, index int, loneService bool) {
 			jLog.Fatal(msg, true)
 		}
 	}
+
+	// IgnoreVersions
+	checkIgnoreVersions(s.IgnoreVersions, target)
+
+	// MinReleaseAge
+	if s.MinReleaseAge != "" {
+		if _, err := time.ParseDuration(s.MinReleaseAge); err != nil {
+			msg := fmt.Sprintf("%s.min_release_age (%s) is invalid (Use 'AhBmCs' duration format)", target, s.MinReleaseAge)
+			jLog.Fatal(msg, true)
+		}
+	}
+
+	// Assets/Signature
+	s.Assets.checkValues(target)
+	s.Signature.checkValues(target)
+	for _, name := range s.gitHubOnly() {
+		msg := fmt.Sprintf("%s.%s can only be used with type: github", target, name)
+		jLog.Fatal(msg, target != "defaults")
+	}
+
+	// Message templates
+	checkTemplate(s.Slack.Message, target+".slack.message")
+	checkTemplate(s.Gotify.Message, target+".gotify.message")
+	checkTemplate(s.Gotify.Title, target+".gotify.title")
+}
+
+// gitHubOnly returns the vars that are set on this Service but need release data, so can only be used with type: github
+// (none when it is type: github).
+func (s *Service) gitHubOnly() []string {
+	if s.Type == "github" {
+		return nil
+	}
+	var names []string
+	if len(s.Assets.Require) != 0 {
+		names = append(names, "assets")
+	}
+	if s.Signature.Type != "" {
+		names = append(names, "signature")
+	}
+	return names
+}
+
+// GitHubRelease is the subset of a GitHub API release that is tracked.
+type GitHubRelease struct {
+	TagName     string        `json:"tag_name"`     // "v1.2.3"
+	Name        string        `json:"name"`         // "Release 1.2.3"
+	Body        string        `json:"body"`         // The release notes.
+	HTMLURL     string        `json:"html_url"`     // "https://github.com/owner/repo/releases/tag/v1.2.3"
+	PublishedAt time.Time     `json:"published_at"` // When the release was published.
+	Assets      []GitHubAsset `json:"assets"`       // The files of the release.
+}
+
+// GitHubAsset is a file of a GitHub release.
+type GitHubAsset struct {
+	Name               string `json:"name"`                 // "app-linux-amd64.tar.gz"
+	BrowserDownloadURL string `json:"browser_download_url"` // "https://github.com/owner/repo/releases/download/v1.2.3/app-linux-amd64.tar.gz"
 }
 
 // status is the current state of the Service element (version and regex misses).
+//
+// The version (and candidate) vars are guarded by mutex as they're read outside of query().
+// The miss counters are only used by query().
 type status struct {
-	version            string // Latest version found from query().
-	regexMissesContent uint   // Counter for the number of regex misses on URL content.
-	regexMissesVersion uint   // Counter for the number of regex misses on version.
-	serviceMisses      string // "1000" 1 = miss, 0 = no miss for split etc.
+	version               string        // Latest version found from query().
+	previousVersion       string        // The version before version.
+	previousTag           string        // The tag of previousVersion.
+	release               GitHubRelease // Release data for the latest version (type:github).
+	detected              time.Time     // When version was found.
+	regexMissesContent    uint          // Counter for the number of regex misses on URL content.
+	regexMissesVersion    uint          // Counter for the number of regex misses on version.
+	serviceMisses         string        // "1000" 1 = miss, 0 = no miss for split etc.
+	assetsPending         string        // The version waiting on its assets (to only log once).
+	signaturePending      string        // The version waiting on its signature.
+	signaturePendingSince time.Time     // When signaturePending was first found unsigned (or unchecked).
+	signature             string        // Result of checking the signature of version (verified/unsigned/unchecked/bad).
+	signatureError        string        // Why the signature of version isn't verified.
+	ignored               string        // The last version ignored (to only log once).
+	candidate             string        // The new version waiting on its confirmations.
+	candidateCount        uint          // Number of queries in a row candidate has been found on.
+	candidateSince        time.Time     // When candidate was first found.
+	latest                string        // The version seen by the last successful query (even if it's older than version).
+	latestSince           time.Time     // When latest was first seen.
+	releases              []time.Time   // When the last adaptiveReleases releases were made (for Adaptive).
+	failures              uint          // Number of queries in a row that failed (to back off).
+	mutex                 sync.RWMutex  // Lock for the version vars.
 }
 
-// init initialises the status vars when more than the default value is needed.
-func (s *status) init() {
-	s.serviceMisses = "0000"
+// newStatus returns a status with the vars initialised where more than the default value is needed.
+func newStatus() *status {
+	return &status{serviceMisses: "0000"}
+}
+
+// setEvent will set the versions of event (and the release data of the latest and when it was found).
+func (s *status) setEvent(event *ReleaseEvent) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	event.PreviousVersion = s.previousVersion
+	event.PreviousTag = s.previousTag
+	event.Version = s.version
+	event.Release = s.release
+	event.Detected = s.detected
+	event.Signature = s.signature
+	event.SignatureError = s.signatureError
+}
+
+// setSignature will set the result of checking the signature of the version being moved to.
+func (s *status) setSignature(signature string, signatureError string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.signature = signature
+	s.signatureError = signatureError
+}
+
+// getVersion returns the latest version found.
+func (s *status) getVersion() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.version
+}
+
+// setVersion sets the latest version found (and its release data), moving the old one to previousVersion.
+func (s *status) setVersion(version string, release GitHubRelease) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.previousVersion = s.version
+	s.previousTag = valueOrValueString(s.release.TagName, s.version)
+	s.version = version
+	s.release = release
+	s.detected = time.Now().UTC()
+}
+
+// rollback sets the version back to version (the latest again after the release of the current version was retracted),
+// so later releases are compared against it.
+func (s *status) rollback(version string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.version = version
+	s.release = GitHubRelease{}
+	s.signature, s.signatureError = "", ""
+	s.detected = time.Now().UTC()
+}
+
+// setCandidate will count a query that found the new version, returning the number of queries in a row it's been found on.
+func (s *status) setCandidate(version string) uint {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if version != s.candidate {
+		s.candidate = version
+		s.candidateCount = 0
+		s.candidateSince = time.Now().UTC()
+	}
+	s.candidateCount++
+	return s.candidateCount
+}
+
+// resetCandidate will forget the candidate (e.g. as another version was found).
+func (s *status) resetCandidate() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.candidate = ""
+	s.candidateCount = 0
+	s.candidateSince = time.Time{}
+}
+
+// getState returns the ServiceState of this status (that's kept in the State for '-status').
+func (s *status) getState(confirmations uint) ServiceState {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	serviceState := ServiceState{
+		Version:  s.version,
+		Detected: s.detected,
+		Releases: append([]time.Time{}, s.releases...),
+	}
+	if s.candidate != "" {
+		serviceState.Candidate = &Candidate{
+			Version:       s.candidate,
+			Confirmations: s.candidateCount,
+			Required:      confirmations,
+			Since:         s.candidateSince,
+		}
+	}
+	return serviceState
+}
+
+// getLatest returns the version seen by the last successful query, and since when it's been seen.
+func (s *status) getLatest() (string, time.Time) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.latest, s.latestSince
+}
+
+// setLatest sets the version seen by the last successful query.
+func (s *status) setLatest(version string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if version != s.latest {
+		s.latest = version
+		s.latestSince = time.Now().UTC()
+	}
 }
 
 // setDefaults sets undefined variables to their default.
@@ -338,6 +590,16 @@ func (s *ServiceSlice) setDefaults(monitorID string, defaults Defaults) {
 
 // setDefaults sets undefined variables to their default.
 func (s *Service) setDefaults(defaults Defaults) {
+	s.status = newStatus()
+
+	// Fold the skip_* bools into Skip.
+	skips := []bool{s.SkipExec, s.SkipGotify, s.SkipSlack, s.SkipWebHook}
+	for index, kind := range []string{"exec", "gotify", "slack", "webhook"} {
+		if skips[index] {
+			s.Skip = append(s.Skip, kind)
+		}
+	}
+
 	// Default GitHub Access Token.
 	s.AccessToken = valueOrValueString(s.AccessToken, defaults.Service.AccessToken)
 
@@ -345,6 +607,40 @@ func (s *Service) setDefaults(defaults Defaults) {
 	s.AllowInvalidCerts = valueOrValueString(s.AllowInvalidCerts, defaults.Service.AllowInvalidCerts)
 	s.AllowInvalidCerts = stringBool(s.AllowInvalidCerts, "", "", false)
 
+	// Default comparison of the old and new versions.
+	s.Compare = valueOrValueString(s.Compare, defaults.Service.Compare)
+	s.Compare = stringBool(s.Compare, "", "", false)
+	s.CompareMaxPRs = valueOrValueUInt(s.CompareMaxPRs, defaults.Service.CompareMaxPRs)
+
+	// Default adaptive interval.
+	s.Adaptive = valueOrValueString(s.Adaptive, defaults.Service.Adaptive)
+	s.Adaptive = stringBool(s.Adaptive, "", "", false)
+	s.MinInterval = valueOrValueString(s.MinInterval, defaults.Service.MinInterval)
+	s.MaxInterval = valueOrValueString(s.MaxInterval, defaults.Service.MaxInterval)
+
+	// Default schedule.
+	s.Schedule = valueOrValueString(s.Schedule, defaults.Service.Schedule)
+	s.Timezone = valueOrValueString(s.Timezone, defaults.Service.Timezone)
+	if s.Calendar == nil && defaults.Service.Calendar != nil {
+		calendar := *defaults.Service.Calendar
+		s.Calendar = &calendar
+	}
+
+	// Default confirmations of new versions.
+	s.Confirmations = valueOrValueUInt(s.Confirmations, defaults.Service.Confirmations)
+
+	// Default soak period.
+	s.MinReleaseAge = valueOrValueString(s.MinReleaseAge, defaults.Service.MinReleaseAge)
+
+	// Default OSV lookup.
+	s.OSV.setDefaults(defaults.Service.OSV)
+
+	// Default asset verification.
+	s.Assets.setDefaults(defaults.Service.Assets)
+
+	// Default signature verification.
+	s.Signature.setDefaults(defaults.Service.Signature, s.Assets)
+
 	// Default progressive versioning (versions have to be successive to notify)
 	s.ProgressiveVersioning = valueOrValueString(s.ProgressiveVersioning, defaults.Service.ProgressiveVersioning)
 	s.ProgressiveVersioning = stringBool(s.ProgressiveVersioning, "", "", true)
@@ -433,9 +729,26 @@ func (c *URLCommand) setDefaults(defaults Defaults, service *Service) {
 	c.IgnoreMiss = stringBool(c.IgnoreMiss, "", "", false)
 }
 
-// setVersion sets Service.Version to v.
-func (s *Service) setVersion(v string) {
-	s.status.version = v
+// getServiceURL returns the web URL of the Service.
+//
+// e.g. type:github - "https://api.github.com/repos/owner/repo/releases/latest"
+//
+// becomes "https://github.com/owner/repo"
+func (s *Service) getServiceURL() string {
+	if s.Type != "github" {
+		return s.URL
+	}
+	return fmt.Sprintf("https://github.com/%s", s.getRepository())
+}
+
+// getRepository returns the "owner/repo" of a type:github Service,
+// otherwise the Service ID.
+func (s *Service) getRepository() string {
+	if s.Type != "github" || !strings.Contains(s.URL, "github.com/repos/") {
+		return s.ID
+	}
+	repo := strings.Split(s.URL, "github.com/repos/")[1]
+	return fmt.Sprintf("%s/%s", strings.Split(repo, "/")[0], strings.Split(repo, "/")[1])
 }
 
 // regexCheckContent returns whether there is a regex match of re on text.
@@ -464,13 +777,28 @@ func getAtIndex(str string, index int) string {
 	return str[index : index+1]
 }
 
+// The results of a query.
+const (
+	queryNoChange   = iota // No new (or retracted) release.
+	queryNewRelease        // A new release was found.
+	queryRetracted         // The latest release was retracted (an older version is the latest again).
+	queryFailed            // The query failed (so back off).
+)
+
 // query queries the Service source, updating Service.Version
-// and returning true if it has changed (is a new release),
-// otherwise returns false.
+// and returning queryNewRelease if it has changed (is a new release),
+// queryRetracted if the latest version went backwards (with progressive versioning),
+// queryFailed if the source couldn't be queried, otherwise returns queryNoChange.
 //
 // index = index of this Service in the parent Monitor
 // monitorID = ID of the parent Monitor
-func (s *Service) query(index int, monitorID string) bool {
+func (s *Service) query(index int, monitorID string) (result int) {
+	// Keep the state for '-status' up to date.
+	defer func() {
+		s.status.setFailed(result == queryFailed)
+		state.setService(monitorID, s.ID, s.status.getState(s.Confirmations))
+	}()
+
 	customTransport := &http.Transport{}
 	// HTTPS insecure skip verify.
 	if s.AllowInvalidCerts == "y" {
@@ -481,7 +809,7 @@ func (s *Service) query(index int, monitorID string) bool {
 	if err != nil {
 		msg := fmt.Sprintf("%s, %s", s.ID, err)
 		jLog.Error(msg, true)
-		return false
+		return queryFailed
 	}
 
 	if s.AccessToken != "" {
@@ -496,11 +824,18 @@ func (s *Service) query(index int, monitorID string) bool {
 		if strings.Contains(err.Error(), "x509") {
 			msg := fmt.Sprintf("x509 for %s (%s) (Cert invalid)", s.ID, monitorID)
 			jLog.Warn(msg, true)
-			return false
+			return queryFailed
 		}
 		msg := fmt.Sprintf("%s (%s), %s", s.ID, monitorID, err)
 		jLog.Error(msg, true)
-		return false
+		return queryFailed
+	}
+
+	// Back off from a source that's rate limiting us or erroring.
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		msg := fmt.Sprintf("%s (%s), %s returned %s", s.ID, monitorID, s.URL, resp.Status)
+		jLog.Warn(msg, true)
+		return queryFailed
 	}
 
 	// Read the response body.
@@ -508,11 +843,12 @@ func (s *Service) query(index int, monitorID string) bool {
 	if err != nil {
 		msg := fmt.Sprintf("%s (%s), %s", s.ID, monitorID, err)
 		jLog.Error(msg, true)
-		return false
+		return queryFailed
 	}
 	// Convert the body to string.
 	body := string(rawBody)
 	version := body
+	var release GitHubRelease
 
 	// GitHub service.
 	if s.Type == "github" {
@@ -524,17 +860,23 @@ func (s *Service) query(index int, monitorID string) bool {
 
 				msg = fmt.Sprintf("tag_name not found for %s (%s) at %s\n%s", s.ID, monitorID, s.URL, body)
 				jLog.Error(msg, true)
-				return false
+				return queryFailed
 			}
 			if strings.Contains(body, "rate limit") {
 				msg := fmt.Sprintf("Rate limit reached on %s (%s)", s.ID, monitorID)
 				jLog.Warn(msg, true)
-				return false
+				return queryFailed
 			}
 		}
 		version = strings.Split(body, `"tag_name"`)[1]
 		version = strings.Split(version, ",")[0]
 		version = strings.Split(version, `"`)[1]
+
+		// Keep the release data for WebHook payloads.
+		if err := json.Unmarshal(rawBody, &release); err != nil {
+			msg := fmt.Sprintf("%s (%s), failed to parse the release data\n%s", s.ID, monitorID, err)
+			jLog.Debug(msg, true)
+		}
 		// Raw URL Service.
 	}
 
@@ -542,33 +884,60 @@ func (s *Service) query(index int, monitorID string) bool {
 	version, err = s.URLCommands.run(monitorID, s, version)
 	// If URLCommands failed, return
 	if err != nil {
-		return false
+		return queryNoChange
 	}
 
 	// If this version is different (new).
-	if version != s.status.version {
-		// Check for a progressive change in version.
-		if s.ProgressiveVersioning == "y" && s.status.version != "" {
+	currentVersion := s.status.getVersion()
+	if version != currentVersion {
+		// Never move to an ignored version.
+		if ignoresVersion(s.IgnoreVersions, version) {
+			msg := fmt.Sprintf("%s (%s), Ignoring version %s (ignore_versions)", s.ID, monitorID, version)
+			jLog.Verbose(msg, s.status.ignored != version)
+			s.status.ignored = version
+			return queryNoChange
+		}
+
+		// Check for the version going backwards (a retraction).
+		if currentVersion != "" {
 			failedSemanticVersioning := false
-			oldVersion, err := semver.NewVersion(s.status.version)
+			oldVersion, err := semver.NewVersion(currentVersion)
 			if err != nil {
-				msg := fmt.Sprintf("%s (%s), failed converting '%s' to a semantic version", s.ID, monitorID, s.status.version)
-				jLog.Error(msg, true)
+				// Only an error with progressive versioning (where versions have to be semantic).
+				msg := fmt.Sprintf("%s (%s), failed converting '%s' to a semantic version", s.ID, monitorID, currentVersion)
+				jLog.Error(msg, s.ProgressiveVersioning == "y")
 				failedSemanticVersioning = true
 			}
 			newVersion, err := semver.NewVersion(version)
 			if err != nil {
 				msg := fmt.Sprintf("%s (%s), failed converting '%s' to a semantic version", s.ID, monitorID, version)
-				jLog.Error(msg, true)
+				jLog.Error(msg, s.ProgressiveVersioning == "y")
 				failedSemanticVersioning = true
 			}
 
 			// e.g.
 			// newVersion = 1.2.9
 			// oldVersion = 1.2.10
-			// return false (don't notify anything. Stay on oldVersion)
+			// return queryRetracted (don't notify of a new release. Monitor.check moves back to newVersion)
 			if !failedSemanticVersioning && newVersion.LessThan(*oldVersion) {
-				return false
+				// Wait for the older version to be found on enough queries in a row too (so a flapping page isn't a retraction).
+				if count := s.status.setCandidate(version); count < s.Confirmations {
+					msg := fmt.Sprintf("%s (%s), Older version %s found %d/%d times in a row", s.ID, monitorID, version, count, s.Confirmations)
+					jLog.Verbose(msg, true)
+					return queryNoChange
+				}
+
+				// The release was retracted (so cancel soaking sends of it).
+				// Only report it the first time the latest goes backwards.
+				latest, _ := s.status.getLatest()
+				s.status.setLatest(version)
+				s.status.resetCandidate()
+				if latest != currentVersion {
+					return queryNoChange
+				}
+				msg := fmt.Sprintf("%s (%s), Release Retracted - %s (the latest is now %s)", s.ID, monitorID, currentVersion, version)
+				jLog.Info(msg, true)
+				return queryRetracted
 			}
 		}
 
@@ -579,7 +948,7 @@ func (s *Service) query(index int, monitorID string) bool {
 				msg := fmt.Sprintf("%s (%s), Regex not matched on content for version %s", s.ID, monitorID, version)
 				s.status.regexMissesContent++
 				jLog.Verbose(msg, s.status.regexMissesContent == 1)
-				return false
+				return queryNoChange
 			}
 		}
 		// Check that the version grabbed satisfies the specified regex (if there is any).
@@ -589,7 +958,7 @@ func (s *Service) query(index int, monitorID string) bool {
 				msg := fmt.Sprintf("%s (%s), Regex not matched on version %s", s.ID, monitorID, version)
 				s.status.regexMissesVersion++
 				jLog.Verbose(msg, s.status.regexMissesVersion == 1)
-				return false
+				return queryNoChange
 			}
 		}
 
@@ -598,7 +967,7 @@ func (s *Service) query(index int, monitorID string) bool {
 		s.status.regexMissesVersion = 0
 
 		// First version found.
-		if s.status.version == "" {
+		if currentVersion == "" {
 			if s.ProgressiveVersioning == "y" {
 				if _, err := semver.NewVersion(version); err != nil {
 					msg := fmt.Sprintf("%s (%s), failed converting '%s' to a semantic version. If all versions are in this style, consider adding url_commands to get the version into the style of '1.2.3a' (https://semver.org/), or disabling progressive versioning (globally with defaults.service.progressive_versioning or just for this service with the progressive_versioning var)", s.ID, monitorID, version)
@@ -606,20 +975,58 @@ func (s *Service) query(index int, monitorID string) bool {
 				}
 			}
 
-			s.setVersion(version)
+			s.status.setVersion(version, release)
+			s.status.setLatest(version)
+			if !release.PublishedAt.IsZero() {
+				s.status.addRelease(release.PublishedAt)
+			}
 			msg := fmt.Sprintf("%s (%s), Starting Release - %s", s.ID, monitorID, version)
 			jLog.Info(msg, true)
 			// Don't notify on first version.
-			return false
+			return queryNoChange
+		}
+
+		// Wait for the version to be found on enough queries in a row (so flapping between versions doesn't notify).
+		if count := s.status.setCandidate(version); count < s.Confirmations {
+			msg := fmt.Sprintf("%s (%s), New version %s found %d/%d times in a row", s.ID, monitorID, version, count, s.Confirmations)
+			jLog.Verbose(msg, true)
+			return queryNoChange
 		}
 
+		// Wait for the release to be signed (for up to signature.wait).
+		firstCheck := s.status.signaturePending != version
+		signed, err := s.checkSignature(release, version, monitorID)
+		if err != nil {
+			msg := fmt.Sprintf("%s (%s), Release %s isn't verified yet, %s", s.ID, monitorID, version, err)
+			jLog.Verbose(msg, firstCheck)
+			return queryNoChange
+		}
+
+		// Wait for the release to be complete (and verified).
+		if err := s.checkAssets(release, version, signed); err != nil {
+			msg := fmt.Sprintf("%s (%s), Release %s isn't complete yet, %s", s.ID, monitorID, version, err)
+			jLog.Verbose(msg, s.status.assetsPending != version)
+			s.status.assetsPending = version
+			return queryNoChange
+		}
+		s.status.assetsPending = ""
+
 		// New version found.
-		s.setVersion(version)
+		s.status.resetCandidate()
+		s.status.setVersion(version, release)
+		s.status.setLatest(version)
+		released := release.PublishedAt
+		if released.IsZero() {
+			released = time.Now()
+		}
+		s.status.addRelease(released)
 		msg := fmt.Sprintf("%s (%s), New Release - %s", s.ID, monitorID, version)
 		jLog.Info(msg, true)
-		return true
+		return queryNewRelease
 	}
 
 	// No version change.
-	return false
+	s.status.resetCandidate()
+	s.status.setLatest(version)
+	return queryNoChange
 }
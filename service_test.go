@@ -1,6 +1,9 @@
 package main
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"regexp"
 	"testing"
 )
@@ -16,9 +19,113 @@ func TestServiceQuery(t *testing.T) {
 
 	config.Monitor[2].Service[0].AccessToken = ""
 	_ = config.Monitor[2].Service[0].query(0, config.Monitor[2].ID)
-	got := config.Monitor[2].Service[0].status.version
+	got := config.Monitor[2].Service[0].status.getVersion()
 
 	if !want.MatchString(got) {
 		t.Fatalf(`%s.status.version = %v, want match for %s`, config.Monitor[1].Service[0].ID, got, want)
 	}
 }
+
+func TestServiceQueryConfirmations(t *testing.T) {
+	version := "1.2.3"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, version)
+	}))
+	defer server.Close()
+	svc := Service{ID: "Service", Type: "url", URL: server.URL, ProgressiveVersioning: "n", Confirmations: 2, status: newStatus()}
+
+	// Flapping between the old and new version shouldn't change the version.
+	for _, test := range []struct {
+		found string
+		want  int
+	}{
+		{"1.2.3", queryNoChange}, // Starting version.
+		{"1.2.4", queryNoChange}, // 1/2
+		{"1.2.3", queryNoChange},
+		{"1.2.4", queryNoChange}, // 1/2
+		{"1.2.4", queryNewRelease},
+	} {
+		version = test.found
+		if got := svc.query(0, "Monitor"); got != test.want {
+			t.Fatalf(`query() of %s = %d, want match for %d`, test.found, got, test.want)
+		}
+		if test.found == "1.2.4" && test.want == queryNoChange {
+			if got := svc.status.getState(svc.Confirmations).Candidate; got == nil || got.Version != "1.2.4" || got.Confirmations != 1 {
+				t.Fatalf(`candidate = %+v, want match for 1.2.4 found 1/2 times`, got)
+			}
+		}
+	}
+	if got := svc.status.getVersion(); got != "1.2.4" {
+		t.Fatalf(`version = %s, want match for 1.2.4`, got)
+	}
+	if got := svc.status.getState(svc.Confirmations).Candidate; got != nil {
+		t.Fatalf(`candidate = %+v, want match for <nil>`, got)
+	}
+}
+
+func TestServiceRetraction(t *testing.T) {
+	version := "1.4.0"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, version)
+	}))
+	defer server.Close()
+
+	for _, progressive := range []string{"y", "n"} {
+		monitor := Monitor{ID: "Monitor", Service: ServiceSlice{{ID: "Service", Type: "url", URL: server.URL, Interval: "1h", ProgressiveVersioning: progressive, Confirmations: 1, status: newStatus()}}}
+		svc := &monitor.Service[0]
+		retractions := len(state.Retractions)
+		for _, test := range []struct {
+			found string
+			want  string
+		}{
+			{"1.4.0", "1.4.0"}, // Starting version.
+			{"1.5.0", "1.5.0"}, // New release.
+			{"1.4.0", "1.4.0"}, // 1.5.0 was pulled, so back to 1.4.0.
+			{"1.4.1", "1.4.1"}, // A fix release below the retracted one.
+		} {
+			version = test.found
+			monitor.check(0, Defaults{})
+			if got := svc.status.getVersion(); got != test.want {
+				t.Fatalf(`progressive_versioning=%s, version after finding %s = %s, want match for %s`, progressive, test.found, got, test.want)
+			}
+		}
+		if got := len(state.Retractions) - retractions; got != 1 {
+			t.Fatalf(`progressive_versioning=%s, %d retractions, want match for 1`, progressive, got)
+		}
+	}
+}
+
+func TestServiceRetractionConfirmations(t *testing.T) {
+	version := "1.4.0"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, version)
+	}))
+	defer server.Close()
+
+	monitor := Monitor{ID: "Monitor", Service: ServiceSlice{{ID: "Service", Type: "url", URL: server.URL, Interval: "1h", ProgressiveVersioning: "n", Confirmations: 2, status: newStatus()}}}
+	svc := &monitor.Service[0]
+	retractions := len(state.Retractions)
+	for _, test := range []struct {
+		found string
+		want  string
+	}{
+		{"1.4.0", "1.4.0"}, // Starting version.
+		{"1.5.0", "1.4.0"}, // 1/2.
+		{"1.5.0", "1.5.0"}, // New release.
+		{"1.4.0", "1.5.0"}, // Flapping, 1/2.
+		{"1.5.0", "1.5.0"},
+		{"1.4.0", "1.5.0"}, // 1/2 again.
+		{"1.5.0", "1.5.0"},
+		{"1.4.0", "1.5.0"}, // 1/2.
+		{"1.4.0", "1.4.0"}, // Retracted.
+	} {
+		version = test.found
+		monitor.check(0, Defaults{})
+		if got := svc.status.getVersion(); got != test.want {
+			t.Fatalf(`version after finding %s = %s, want match for %s`, test.found, got, test.want)
+		}
+	}
+	if got := len(state.Retractions) - retractions; got != 1 {
+		t.Fatalf(`%d retractions, want match for 1`, got)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The severities a release can be classified as (most severe first).
+const (
+	severitySecurity = "security" // Fixes a vulnerability.
+	severityBreaking = "breaking" // Has breaking changes (or is a major version bump).
+	severityNormal   = "normal"   // Everything else.
+)
+
+// severities are the valid severities.
+var severities = []string{severitySecurity, severityBreaking, severityNormal}
+
+var (
+	// severitySecurityRules match release notes of a security release.
+	severitySecurityRules = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\bsecurity\b`),
+		regexp.MustCompile(`(?i)\bvulnerabilit(y|ies)\b`),
+		regexp.MustCompile(`\bCVE-[0-9]{4}-[0-9]{4,}\b`),
+		regexp.MustCompile(`\bGHSA(-[23456789cfghjmpqrvwx]{4}){3}\b`),
+		regexp.MustCompile(`github\.com/[^/\s]+/[^/\s]+/security/advisories`),
+	}
+	// severityBreakingRules match release notes of a release with breaking changes.
+	severityBreakingRules = []*regexp.Regexp{
+		regexp.MustCompile(`\bBREAKING\b`),
+		regexp.MustCompile(`(?i)\bbreaking[ -]changes?\b`),
+		regexp.MustCompile(`(?i)\bbackwards?[ -]incompatible\b`),
+	}
+)
+
+// classifySeverity returns the severities of the release of event (most severe first) from its notes and version change.
+//
+// A release can be both security and breaking, otherwise it's normal.
+func classifySeverity(event *ReleaseEvent) []string {
+	text := event.Release.Name + "\n" + event.Notes
+	// Fixes known vulnerabilities of the deployed version.
+	security := event.Vulnerabilities != nil && event.Vulnerabilities.FixedIn != "" &&
+		compareVersions(event.Version, event.Vulnerabilities.FixedIn) >= 0
+	for _, rule := range severitySecurityRules {
+		security = security || rule.MatchString(text)
+	}
+
+	breaking := getChangeLevel(event.PreviousVersion, event.Version) == "major"
+	for _, rule := range severityBreakingRules {
+		breaking = breaking || rule.MatchString(text)
+	}
+
+	var classes []string
+	if security {
+		classes = append(classes, severitySecurity)
+	}
+	if breaking {
+		classes = append(classes, severityBreaking)
+	}
+	if len(classes) == 0 {
+		classes = append(classes, severityNormal)
+	}
+	return classes
+}
+
+// hasSeverity returns whether any of severities is in list.
+func hasSeverity(list []string, severities []string) bool {
+	for _, item := range list {
+		for _, severity := range severities {
+			if item == severity {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkSeverity will fatal if severity isn't a valid severity (target is where in the config it is).
+func checkSeverity(severity string, target string) {
+	for _, valid := range severities {
+		if severity == valid {
+			return
+		}
+	}
+	msg := fmt.Sprintf("%s (%s) is not a severity (Use '%s')", target, severity, strings.Join(severities, "', '"))
+	jLog.Fatal(msg, true)
+}
+
+// skipsSeverity returns whether these NotifyOptions skip releases of any of severities.
+func (o *NotifyOptions) skipsSeverity(severities []string) bool {
+	return hasSeverity(o.SkipSeverity, severities)
+}
+
+// printSeverityMap will print the map of severity to value under name (if it's not empty).
+func printSeverityMap(prefix string, name string, severityMap map[string]string) {
+	if len(severityMap) == 0 {
+		return
+	}
+	var keys []string
+	for severity := range severityMap {
+		keys = append(keys, severity)
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("%s%s:\n", prefix, name)
+	for _, severity := range keys {
+		fmt.Printf("%s  %s: '%s'\n", prefix, severity, severityMap[severity])
+	}
+}
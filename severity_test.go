@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassifySeverity(t *testing.T) {
+	tests := []struct {
+		previous string
+		version  string
+		notes    string
+		want     string
+	}{
+		{"1.2.2", "1.2.3", "Fixes CVE-2022-12345", severitySecurity},
+		{"1.2.2", "1.2.3", "See https://github.com/owner/repo/security/advisories/GHSA-abcd-1234-wxyz", severitySecurity},
+		{"1.2.2", "1.2.3", "Patched a Security issue", severitySecurity},
+		{"1.2.2", "1.3.0", "BREAKING: removed the old API", severityBreaking},
+		{"1.2.2", "1.3.0", "### Breaking changes", severityBreaking},
+		{"1.2.2", "2.0.0", "Lots of new things", severityBreaking},
+		{"1.2.2", "2.0.0", "Fixes CVE-2022-12345", severitySecurity + "," + severityBreaking},
+		{"1.2.2", "1.2.3", "Fixed a typo (not breaking anything)", severityNormal},
+	}
+	for _, test := range tests {
+		event := &ReleaseEvent{PreviousVersion: test.previous, Version: test.version, Notes: test.notes}
+		if got := strings.Join(classifySeverity(event), ","); got != test.want {
+			t.Fatalf(`classifySeverity(%s -> %s, %q) = %s, want match for %s`, test.previous, test.version, test.notes, got, test.want)
+		}
+	}
+}
+
+func TestSkipsSeverity(t *testing.T) {
+	options := NotifyOptions{SkipSeverity: []string{severityBreaking}}
+	if !options.skipsSeverity([]string{severitySecurity, severityBreaking}) {
+		t.Fatalf(`skipsSeverity() of a security and breaking release = false, want match for true`)
+	}
+	if options.skipsSeverity([]string{severitySecurity}) {
+		t.Fatalf(`skipsSeverity() of a security release = true, want match for false`)
+	}
+}
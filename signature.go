@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The results of verifying the signature of a release.
+const (
+	signatureVerified  = "verified"  // Signed by the pinned key.
+	signatureUnsigned  = "unsigned"  // No signed file, or no signature of it, on the release.
+	signatureUnchecked = "unchecked" // The signature couldn't be checked (e.g. a failed download, or gpg/cosign isn't installed).
+	signatureBad       = "bad"       // gpg/cosign rejected the signature.
+)
+
+// Signature is how to verify that a release is signed by a pinned key (type:github).
+//
+// It's the checksum file that's signed, so use it with assets.verify to cover the assets themselves.
+type Signature struct {
+	Type      string `yaml:"type"`      // "gpg"/"cosign"
+	Key       string `yaml:"key"`       // Path of the pinned public key (an armored GPG key, or a cosign.pub).
+	File      string `yaml:"file"`      // default - assets.checksums = Glob of the signed file on the release.
+	Signature string `yaml:"signature"` // default - "${file}.asc"/"${file}.sig"/"${file}.gpg" (gpg), "${file}.bundle"/"${file}.sig" (cosign) = Glob of the signature on the release.
+	Wait      string `yaml:"wait"`      // default - 1h = How long to keep checking an unsigned (or unchecked) release for its signature before it's notified as that.
+}
+
+// signatureDefaults are the default signature globs of each Signature.Type.
+var signatureDefaults = map[string][]string{
+	"gpg":    {"${file}.asc", "${file}.sig", "${file}.gpg"},
+	"cosign": {"${file}.bundle", "${file}.sig"},
+}
+
+// signatureExtensions are the extensions of signature (and certificate) assets,
+// which are never the signed file or a checksum file.
+var signatureExtensions = []string{".asc", ".sig", ".gpg", ".pem", ".bundle"}
+
+// setDefaults sets undefined variables to their default.
+func (s *Signature) setDefaults(defaults Signature, assets Assets) {
+	s.Type = valueOrValueString(s.Type, defaults.Type)
+	s.Key = valueOrValueString(s.Key, defaults.Key)
+	s.File = valueOrValueString(s.File, valueOrValueString(defaults.File, assets.Checksums))
+	s.Signature = valueOrValueString(s.Signature, defaults.Signature)
+	s.Wait = valueOrValueString(s.Wait, defaults.Wait)
+}
+
+// checkValues will check that the Signature is valid (target is where in the config it is).
+func (s *Signature) checkValues(target string) {
+	if s.Type == "" {
+		return
+	}
+	if _, exists := signatureDefaults[s.Type]; !exists {
+		msg := fmt.Sprintf("%s.signature.type (%s) is invalid (use 'gpg' or 'cosign')", target, s.Type)
+		jLog.Fatal(msg, true)
+	}
+	if s.Key == "" {
+		msg := fmt.Sprintf("%s.signature.key is required with type %s", target, s.Type)
+		jLog.Fatal(msg, target != "defaults")
+	}
+	if _, err := time.ParseDuration(s.Wait); err != nil {
+		msg := fmt.Sprintf("%s.signature.wait (%s) is invalid (Use 'AhBmCs' duration format)\n%s", target, s.Wait, err)
+		jLog.Fatal(msg, true)
+	}
+	for name, glob := range map[string]string{"file": s.File, "signature": s.Signature} {
+		if _, err := path.Match(glob, ""); err != nil {
+			msg := fmt.Sprintf("%s.signature.%s (%s) is an invalid glob\n%s", target, name, glob, err)
+			jLog.Fatal(msg, true)
+		}
+	}
+}
+
+// print will print the Signature.
+func (s *Signature) print(prefix string) {
+	if s.Type == "" {
+		return
+	}
+	fmt.Printf("%ssignature:\n", prefix)
+	fmt.Printf("%s  type: %s\n", prefix, s.Type)
+	fmt.Printf("%s  key: '%s'\n", prefix, s.Key)
+	fmt.Printf("%s  file: '%s'\n", prefix, s.File)
+	if s.Signature != "" {
+		fmt.Printf("%s  signature: '%s'\n", prefix, s.Signature)
+	}
+	fmt.Printf("%s  wait: %s\n", prefix, s.Wait)
+}
+
+// checkSignature will check whether release is signed by the pinned key (when this Service has a Signature),
+// returning the contents of the signed file when it is.
+//
+// An unsignedError (or uncheckedError) is returned while release has been unsigned (or couldn't be checked)
+// for less than signature.wait, so that it's checked again on the next query (the signature may be
+// uploaded after the release, and downloads can fail).
+func (s *Service) checkSignature(release GitHubRelease, version string, monitorID string) ([]byte, error) {
+	s.status.setSignature("", "")
+	if s.Signature.Type == "" {
+		return nil, nil
+	}
+
+	signed, err := s.verifySignature(release)
+	if err == nil {
+		s.status.setSignature(signatureVerified, "")
+		return signed, nil
+	}
+	result := signatureBad
+	switch err.(type) {
+	case unsignedError, uncheckedError:
+		if s.status.signaturePending != version {
+			s.status.signaturePending = version
+			s.status.signaturePendingSince = time.Now()
+		}
+		wait, _ := time.ParseDuration(s.Signature.Wait)
+		if time.Since(s.status.signaturePendingSince) < wait {
+			return nil, err
+		}
+		result = signatureUnsigned
+		if _, unchecked := err.(uncheckedError); unchecked {
+			result = signatureUnchecked
+		}
+	}
+	s.status.setSignature(result, err.Error())
+	msg := fmt.Sprintf("%s (%s), %s is %s\n%s", s.ID, monitorID, version, result, err)
+	jLog.Warn(msg, true)
+	return nil, nil
+}
+
+// unsignedError is why a release is unsigned.
+type unsignedError string
+
+func (e unsignedError) Error() string {
+	return string(e)
+}
+
+// uncheckedError is why the signature of a release couldn't be checked (rather than being rejected).
+type uncheckedError string
+
+func (e uncheckedError) Error() string {
+	return string(e)
+}
+
+// verifySignature returns the contents of the signed file of release when it's signed by the pinned key.
+//
+// An unsignedError is returned when it has no signed file or signature, and an uncheckedError
+// when it couldn't be checked (e.g. a download failed, or gpg/cosign couldn't be run).
+func (s *Service) verifySignature(release GitHubRelease) ([]byte, error) {
+	file := findAsset(release, s.Signature.File, false)
+	if file == nil {
+		return nil, unsignedError(fmt.Sprintf("no asset matching '%s'", s.Signature.File))
+	}
+	globs := signatureDefaults[s.Signature.Type]
+	if s.Signature.Signature != "" {
+		globs = []string{s.Signature.Signature}
+	}
+	var signature *GitHubAsset
+	for _, glob := range globs {
+		if signature = findAsset(release, strings.ReplaceAll(glob, "${file}", file.Name), true); signature != nil {
+			break
+		}
+	}
+	if signature == nil {
+		return nil, unsignedError(fmt.Sprintf("no signature of '%s'", file.Name))
+	}
+
+	dir, err := ioutil.TempDir("", "release-notifier-")
+	if err != nil {
+		return nil, uncheckedError(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	paths := map[*GitHubAsset]string{}
+	var signed []byte
+	for _, asset := range []*GitHubAsset{file, signature} {
+		body, err := s.get(asset.BrowserDownloadURL, "")
+		if err != nil {
+			return nil, uncheckedError(fmt.Sprintf("failed to download '%s'\n%s", asset.Name, err))
+		}
+		if asset == file {
+			signed = body
+		}
+		paths[asset] = filepath.Join(dir, filepath.Base(asset.Name))
+		if err := ioutil.WriteFile(paths[asset], body, 0600); err != nil {
+			return nil, uncheckedError(err.Error())
+		}
+	}
+
+	switch s.Signature.Type {
+	case "gpg":
+		// Import the key into a keyring of its own so only it is trusted.
+		home := filepath.Join(dir, "gnupg")
+		if err := os.Mkdir(home, 0700); err != nil {
+			return nil, uncheckedError(err.Error())
+		}
+		if err := runVerifier("gpg", "--homedir", home, "--batch", "--quiet", "--import", s.Signature.Key); err != nil {
+			return nil, uncheckedError(err.Error())
+		}
+		err = runVerifier("gpg", "--homedir", home, "--batch", "--quiet", "--verify", paths[signature], paths[file])
+	default:
+		flag := "--signature"
+		if strings.HasSuffix(signature.Name, ".bundle") {
+			flag = "--bundle"
+		}
+		err = runVerifier("cosign", "verify-blob", "--key", s.Signature.Key, flag, paths[signature], paths[file])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
+
+// findAsset returns the first asset of release matching glob (nil if there's none).
+//
+// Signatures are skipped unless signatures is true (so '*SHA256SUMS*' doesn't match 'SHA256SUMS.asc').
+func findAsset(release GitHubRelease, glob string, signatures bool) *GitHubAsset {
+	for index := range release.Assets {
+		if !signatures && isSignatureAsset(release.Assets[index].Name) {
+			continue
+		}
+		if matched, _ := path.Match(glob, release.Assets[index].Name); matched {
+			return &release.Assets[index]
+		}
+	}
+	return nil
+}
+
+// isSignatureAsset returns whether name is a signature (or certificate) file.
+func isSignatureAsset(name string) bool {
+	for _, extension := range signatureExtensions {
+		if strings.HasSuffix(name, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// runVerifier runs the command, returning its output with the error if it fails.
+//
+// An uncheckedError is returned when the command couldn't be run (e.g. it isn't installed).
+func runVerifier(name string, args ...string) error {
+	var output bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		msg := fmt.Sprintf("%s: %s\n%s", name, err, strings.TrimSpace(output.String()))
+		if _, exited := err.(*exec.ExitError); !exited {
+			return uncheckedError(msg)
+		}
+		return errors.New(msg)
+	}
+	return nil
+}
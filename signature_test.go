@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifySignatureGPG(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not installed")
+	}
+
+	// Sign SHA256SUMS with a throwaway key.
+	dir := t.TempDir()
+	home := filepath.Join(dir, "gnupg")
+	gpg := func(args ...string) []byte {
+		output, err := exec.Command("gpg", append([]string{"--homedir", home, "--batch", "--quiet", "--pinentry-mode", "loopback", "--passphrase", ""}, args...)...).Output()
+		if err != nil {
+			t.Fatalf(`gpg %v = %v`, args, err)
+		}
+		return output
+	}
+	os.Mkdir(home, 0700)
+	gpg("--quick-gen-key", "Test <test@example.com>", "default", "default", "never")
+	files := map[string]string{"SHA256SUMS": "0000  app.tar.gz\n"}
+	sumsPath := filepath.Join(dir, "SHA256SUMS")
+	ioutil.WriteFile(sumsPath, []byte(files["SHA256SUMS"]), 0600)
+	files["SHA256SUMS.asc"] = string(gpg("--armor", "--output", "-", "--detach-sign", sumsPath))
+	keyPath := filepath.Join(dir, "key.asc")
+	ioutil.WriteFile(keyPath, gpg("--armor", "--export"), 0600)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, files[strings.TrimPrefix(r.URL.Path, "/")])
+	}))
+	defer server.Close()
+
+	svc := Service{Signature: Signature{Type: "gpg", Key: keyPath, File: "*SHA256SUMS*", Wait: "1h"}, status: newStatus()}
+	release := GitHubRelease{}
+	// The signature first, so '*SHA256SUMS*' has to skip it for the signed file.
+	for _, name := range []string{"SHA256SUMS.asc", "SHA256SUMS"} {
+		release.Assets = append(release.Assets, GitHubAsset{Name: name, BrowserDownloadURL: server.URL + "/" + name})
+	}
+
+	// Signed by the pinned key.
+	signed, err := svc.checkSignature(release, "1.2.3", "test")
+	if err != nil || svc.status.signature != signatureVerified {
+		t.Fatalf(`checkSignature() = %v, signature %q (%s), want match for %q`, err, svc.status.signature, svc.status.signatureError, signatureVerified)
+	}
+	if string(signed) != files["SHA256SUMS"] {
+		t.Fatalf(`checkSignature() = %q, want match for the signed file %q`, signed, files["SHA256SUMS"])
+	}
+
+	// Tampered with.
+	files["SHA256SUMS"] = "1111  app.tar.gz\n"
+	if signed, err := svc.checkSignature(release, "1.2.3", "test"); signed != nil || err != nil || svc.status.signature != signatureBad {
+		t.Fatalf(`checkSignature() = %q, %v, signature %q, want match for %q`, signed, err, svc.status.signature, signatureBad)
+	}
+
+	// No signature (yet), so it's checked again on the next query.
+	unsigned := GitHubRelease{Assets: release.Assets[1:]}
+	if _, err := svc.checkSignature(unsigned, "1.2.4", "test"); err == nil {
+		t.Fatalf(`checkSignature() = %v, want match for unsigned within the wait`, err)
+	}
+
+	// Still no signature after the wait.
+	svc.Signature.Wait = "0s"
+	if _, err := svc.checkSignature(unsigned, "1.2.4", "test"); err != nil || svc.status.signature != signatureUnsigned {
+		t.Fatalf(`checkSignature() = %v, signature %q, want match for %q`, err, svc.status.signature, signatureUnsigned)
+	}
+
+	// A failed download is checked again on the next query (rather than being a bad signature).
+	svc.Signature.Wait = "1h"
+	failing := GitHubRelease{Assets: []GitHubAsset{release.Assets[1], {Name: "SHA256SUMS.asc", BrowserDownloadURL: "http://127.0.0.1:1/SHA256SUMS.asc"}}}
+	if _, err := svc.checkSignature(failing, "1.2.5", "test"); err == nil {
+		t.Fatalf(`checkSignature() = %v, want match for unchecked within the wait`, err)
+	}
+	svc.Signature.Wait = "0s"
+	if _, err := svc.checkSignature(failing, "1.2.5", "test"); err != nil || svc.status.signature != signatureUnchecked {
+		t.Fatalf(`checkSignature() = %v, signature %q, want match for %q`, err, svc.status.signature, signatureUnchecked)
+	}
+}
+
+func TestRunVerifier(t *testing.T) {
+	// Not installed, so it wasn't checked.
+	if _, unchecked := runVerifier("release-notifier-missing-verifier").(uncheckedError); !unchecked {
+		t.Fatalf(`runVerifier() of a missing command, want match for an uncheckedError`)
+	}
+
+	// Ran and rejected it.
+	if _, err := exec.LookPath("false"); err == nil {
+		if err := runVerifier("false"); err == nil {
+			t.Fatalf(`runVerifier("false") = nil, want match for an error`)
+		} else if _, unchecked := err.(uncheckedError); unchecked {
+			t.Fatalf(`runVerifier("false") = uncheckedError, want match for a rejection`)
+		}
+	}
+}
@@ -7,8 +7,6 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
-	"time"
 )
 
 // SlackSlice is an array of Slack.
@@ -16,13 +14,15 @@ type SlackSlice []Slack
 
 // Slack is a Slack message w/ destination and from details.
 type Slack struct {
-	URL       string `yaml:"url,omitempty"`        // "https://example.com
-	IconEmoji string `yaml:"icon_emoji,omitempty"` // ":github:"
-	IconURL   string `yaml:"icon_url,omitempty"`   // "https://github.githubassets.com/images/modules/logos_page/GitHub-Mark.png"
-	Username  string `yaml:"username,omitempty"`   // "Release Notifier"
-	Message   string `yaml:"message,omitempty"`    // "<${service_url}|${service_id}> - ${version} released"
-	Delay     string `yaml:"delay,omitempty"`      // The delay before sending the Slack message.
-	MaxTries  uint   `yaml:"max_tries,omitempty"`  // Number of times to attempt sending the Slack message if a 200 is not received.
+	URL             string            `yaml:"url,omitempty"`              // "https://example.com
+	IconEmoji       string            `yaml:"icon_emoji,omitempty"`       // ":github:"
+	IconURL         string            `yaml:"icon_url,omitempty"`         // "https://github.githubassets.com/images/modules/logos_page/GitHub-Mark.png"
+	Username        string            `yaml:"username,omitempty"`         // "Release Notifier"
+	Message         string            `yaml:"message,omitempty"`          // "<${service_url}|${service_id}> - ${version} released"
+	NotesMaxLength  uint              `yaml:"notes_max_length,omitempty"` // Length to truncate the release notes ({{ .notes }}) to.
+	Channel         string            `yaml:"channel,omitempty"`          // Channel to send to, rather than the default of the Slack webhook (e.g. "#releases").
+	SeverityChannel map[string]string `yaml:"severity_channel,omitempty"` // Channel to send releases of a severity to (e.g. security: "#security").
+	NotifyOptions   `yaml:",inline"`  // Delay, MaxTries, Retry, ...
 }
 
 // UnmarshalYAML allows handling of a dict as well as a list of dicts.
@@ -58,8 +58,8 @@ func (s *SlackSlice) setDefaults(monitorID string, defaults Defaults) {
 
 // setDefaults sets undefined variables to their default.
 func (s *Slack) setDefaults(defaults Defaults) {
-	// Delay
-	s.Delay = valueOrValueString(s.Delay, defaults.Slack.Delay)
+	// Delay, MaxTries, Retry, SilentFails
+	s.NotifyOptions.setDefaults(defaults.Slack.NotifyOptions)
 
 	// Icon
 	if s.IconEmoji == "" && s.IconURL == "" {
@@ -69,12 +69,20 @@ func (s *Slack) setDefaults(defaults Defaults) {
 		s.IconURL = valueOrValueString(s.IconURL, defaults.Slack.IconURL)
 	}
 
-	// MaxTries
-	s.MaxTries = valueOrValueUInt(s.MaxTries, defaults.Slack.MaxTries)
-
 	// Message
 	s.Message = valueOrValueString(s.Message, defaults.Slack.Message)
 
+	// NotesMaxLength
+	s.NotesMaxLength = valueOrValueUInt(s.NotesMaxLength, defaults.Slack.NotesMaxLength)
+
+	// Channel
+	s.Channel = valueOrValueString(s.Channel, defaults.Slack.Channel)
+
+	// SeverityChannel
+	if s.SeverityChannel == nil {
+		s.SeverityChannel = defaults.Slack.SeverityChannel
+	}
+
 	// Username
 	s.Username = valueOrValueString(s.Username, defaults.Slack.Username)
 }
@@ -92,119 +100,108 @@ func (s *Slack) checkValues(monitorID string, index int, loneService bool) {
 	if !loneService {
 		target = fmt.Sprintf("%s[%d]", monitorID, index)
 	}
+	s.validate(target)
+}
 
-	// Delay
-	if s.Delay != "" {
-		// Default to seconds when an integer is provided
-		if _, err := strconv.Atoi(s.Delay); err == nil {
-			s.Delay += "s"
-		}
-		if _, err := time.ParseDuration(s.Delay); err != nil {
-			msg := fmt.Sprintf("%s.delay (%s) is invalid (Use 'AhBmCs' duration format)", target, s.Delay)
-			jLog.Fatal(msg, true)
-		}
+// validate will check that the variables are valid for this Slack recipient.
+func (s *Slack) validate(target string) {
+	s.NotifyOptions.validate(target)
+	checkTemplate(s.Message, target+".message")
+	for severity := range s.SeverityChannel {
+		checkSeverity(severity, target+".severity_channel")
 	}
 }
 
-// SlackPayload is the payload to be to be sent as the Slack message.
-type SlackPayload struct {
-	Username  string `json:"username"`   // "Release Notifier"
-	IconEmoji string `json:"icon_emoji"` // ":github:"
-	IconURL   string `json:"icon_url"`   // "https://github.githubassets.com/images/modules/logos_page/GitHub-Mark.png"
-	Text      string `json:"text"`       // "${service} - ${version} released"
+// getTarget returns the URL of this Slack recipient.
+func (s *Slack) getTarget() string {
+	return s.URL
 }
 
-// send will send every slack message in this SlackSlice.
-func (s *SlackSlice) send(monitorID string, svc *Service, message string) {
-	for index := range *s {
-		// Send each Slack message up to s.MaxTries number of times until they 200.
-		go func() {
-			index := index                    // Create new instance for the goroutine.
-			triesLeft := (*s)[index].MaxTries // Number of times to send WebHook (until 200 received).
-
-			// Delay sending the Slack message by the defined interval.
-			sleepTime, _ := time.ParseDuration((*s)[index].Delay)
-			msg := fmt.Sprintf("%s, Sleeping for %s before sending the Slack message", monitorID, (*s)[index].Delay)
-			jLog.Info(msg, sleepTime != 0)
-			time.Sleep(sleepTime)
-
-			for {
-				err := (*s)[index].send(monitorID, svc, message)
-
-				// SUCCESS!
-				if err == nil {
-					return
-				}
-
-				// FAIL
-				jLog.Error(err.Error(), true)
-				triesLeft--
-
-				// Give up after MaxTries.
-				if triesLeft == 0 {
-					msg = fmt.Sprintf("%s (%s), Failed %d times to send a Slack message to %s", svc.ID, monitorID, (*s)[index].MaxTries, (*s)[index].URL)
-					jLog.Error(msg, true)
-					return
-				}
-
-				// Space out retries.
-				time.Sleep(10 * time.Second)
-			}
-		}()
-		// Space out Slack messages.const.
-		time.Sleep(3 * time.Second)
+// print will print this Slack recipient as a list element.
+func (s *Slack) print(prefix string) {
+	fmt.Printf("%s- url: '%s'\n", prefix, s.URL)
+	fmt.Printf("%s  icon_emoji: '%s'\n", prefix, s.IconEmoji)
+	fmt.Printf("%s  icon_url: '%s'\n", prefix, s.IconURL)
+	fmt.Printf("%s  username: '%s'\n", prefix, s.Username)
+	fmt.Printf("%s  message: '%s'\n", prefix, s.Message)
+	fmt.Printf("%s  notes_max_length: %d\n", prefix, s.NotesMaxLength)
+	if s.Channel != "" {
+		fmt.Printf("%s  channel: '%s'\n", prefix, s.Channel)
 	}
+	printSeverityMap(prefix+"  ", "severity_channel", s.SeverityChannel)
+	s.NotifyOptions.print(prefix + "  ")
 }
 
-// send sends a formatted Slack notification regarding mon.
-func (s *Slack) send(monitorID string, svc *Service, message string) error {
-	sURL := svc.URL
-	// GitHub monitor. Get the non-API URL.
-	if svc.Type == "github" {
-		sURL = strings.Split(svc.URL, "github.com/repos/")[1]
-		sURL = fmt.Sprintf("https://github.com/%s/%s", strings.Split(sURL, "/")[0], strings.Split(sURL, "/")[1])
-	}
+// SlackPayload is the payload to be to be sent as the Slack message.
+type SlackPayload struct {
+	Username  string `json:"username"`          // "Release Notifier"
+	IconEmoji string `json:"icon_emoji"`        // ":github:"
+	IconURL   string `json:"icon_url"`          // "https://github.githubassets.com/images/modules/logos_page/GitHub-Mark.png"
+	Text      string `json:"text"`              // "${service} - ${version} released"
+	Channel   string `json:"channel,omitempty"` // "#releases"
+}
+
+// getPayload returns the SlackPayload of a formatted Slack notification regarding event.
+func (s *Slack) getPayload(event *ReleaseEvent, message string) (SlackPayload, error) {
+	overrides := event.slack
 
 	// Use 'new release' Slack message (Not a custom message)
 	if message == "" {
-		message = valueOrValueString(svc.Slack.Message, s.Message)
-		message = strings.ReplaceAll(message, "${monitor_id}", monitorID)
-		message = strings.ReplaceAll(message, "${service_url}", sURL)
-		message = strings.ReplaceAll(message, "${service_id}", svc.ID)
-		message = strings.ReplaceAll(message, "${version}", svc.status.version)
+		var err error
+		data := event.getTemplateData()
+		data["notes"] = formatNotesForSlack(event.Notes, s.NotesMaxLength)
+		text := valueOrValueString(overrides.Message, s.Message)
+		message, err = renderTemplate(text, data)
+		if err != nil {
+			return SlackPayload{}, err
+		}
+		// Add the summary of the changes, unless the message has its own.
+		if event.Compare != nil && !templateUses(text, "compare") {
+			message += "\n" + slackEscaper.Replace(event.Compare.format())
+		}
 	}
 
 	payload := SlackPayload{
-		Username:  valueOrValueString(svc.Slack.Username, s.Username),
-		IconEmoji: valueOrValueString(svc.Slack.IconEmoji, s.IconEmoji),
-		IconURL:   valueOrValueString(svc.Slack.IconURL, s.IconURL),
+		Username:  valueOrValueString(overrides.Username, s.Username),
+		IconEmoji: valueOrValueString(overrides.IconEmoji, s.IconEmoji),
+		IconURL:   valueOrValueString(overrides.IconURL, s.IconURL),
 		Text:      message,
+		Channel:   valueOrValueString(s.SeverityChannel[event.Severity], valueOrValueString(overrides.Channel, s.Channel)),
 	}
 	// Handle per-monitor overrides. (Ensure s.Icon* values won't be sent)
-	if svc.Slack.IconEmoji != "" {
+	if overrides.IconEmoji != "" {
 		payload.IconURL = ""
-	} else if svc.Slack.IconURL != "" {
+	} else if overrides.IconURL != "" {
 		payload.IconEmoji = ""
 	}
+	return payload, nil
+}
 
-	payloadData, err := json.Marshal(payload)
+// render returns the JSON of the SlackPayload regarding event (or the custom message).
+func (s *Slack) render(event *ReleaseEvent, title string, message string, defaults Defaults) ([]byte, error) {
+	payload, err := s.getPayload(event, message)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return json.Marshal(payload)
+}
 
-	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(payloadData))
+// send sends the Slack notification payload of delivery.
+func (s *Slack) send(delivery *Delivery) error {
+	monitorID, serviceID := delivery.MonitorID, delivery.ServiceID
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(delivery.Payload))
 	if err != nil {
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), s.Retry.getTimeout())
 	req = req.WithContext(ctx)
 	defer cancel()
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		// If verbose or above, print the error every time
-		msg := fmt.Sprintf("%s (%s), Slack\n%s", svc.ID, monitorID, err)
+		msg := fmt.Sprintf("%s (%s), Slack\n%s", serviceID, monitorID, err)
 		jLog.Verbose(msg, true)
 		return err
 	}
@@ -212,11 +209,12 @@ func (s *Slack) send(monitorID string, svc *Service, message string) error {
 
 	// SUCCESS (2XX)
 	if strconv.Itoa(resp.StatusCode)[:1] == "2" {
-		msg := fmt.Sprintf("%s (%s), Slack message sent", svc.ID, monitorID)
+		msg := fmt.Sprintf("%s (%s), Slack message sent", serviceID, monitorID)
 		jLog.Info(msg, true)
 		return nil
 	}
 
 	// FAIL
-	return fmt.Errorf("%s (%s), Slack message failed to send.\n%s", svc.ID, monitorID, err)
+	err = fmt.Errorf("%s (%s), Slack message failed to send.\n%s", serviceID, monitorID, resp.Status)
+	return newResponseError(resp, err)
 }
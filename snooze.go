@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+)
+
+// Snooze mutes the notifications of a Monitor/Service (to a Notifier, or all of them)
+// until a time, or until a version.
+type Snooze struct {
+	Target       string    `json:"target"`                  // ID of the Monitor or Service to mute.
+	Notifier     string    `json:"notifier,omitempty"`      // Kind ("slack") or ID of the Notifier to mute ("" = all of them).
+	Until        time.Time `json:"until,omitempty"`         // Mute until this time.
+	UntilVersion string    `json:"until_version,omitempty"` // Mute releases older than this version.
+	Created      time.Time `json:"created"`                 // When the Snooze was added.
+}
+
+// Snoozes are the Snooze's in snoozes.json of the data directory.
+//
+// The file is only written by the CLI ('-snooze'/'-unsnooze'). The running Release-Notifier re-reads it when it changes.
+type Snoozes struct {
+	list     []Snooze   // The Snooze's.
+	path     string     // Path of the file ("" = no snoozes).
+	modified time.Time  // When the file was modified when it was last read.
+	mutex    sync.Mutex // Lock for the vars.
+}
+
+// init will initialise the Snoozes to be read from snoozes.json in dir.
+func (s *Snoozes) init(dir string) {
+	if dir != "" {
+		s.path = filepath.Join(dir, "snoozes.json")
+	}
+	s.reload()
+}
+
+// reload will read the Snooze's from the file if it's changed since it was last read.
+func (s *Snoozes) reload() {
+	if s.path == "" {
+		return
+	}
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.list, s.modified = nil, time.Time{}
+		return
+	}
+	if err == nil && info.ModTime().Equal(s.modified) {
+		return
+	}
+
+	var data []byte
+	if err == nil {
+		data, err = ioutil.ReadFile(s.path)
+	}
+	if err == nil {
+		var list []Snooze
+		if err = json.Unmarshal(data, &list); err == nil {
+			s.list = list
+			s.modified = info.ModTime()
+		}
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Failed to read the snoozes from '%s'\n%s", s.path, err)
+		jLog.Error(msg, true)
+	}
+}
+
+// save will write the Snooze's that haven't expired to the file.
+func (s *Snoozes) save() error {
+	active := []Snooze{}
+	for _, snooze := range s.list {
+		if !snooze.expired() {
+			active = append(active, snooze)
+		}
+	}
+	data, err := json.MarshalIndent(active, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return err
+	}
+	s.list = active
+	return nil
+}
+
+// expired returns whether the Snooze no longer mutes anything.
+func (s *Snooze) expired() bool {
+	return s.UntilVersion == "" && !s.Until.IsZero() && time.Now().After(s.Until)
+}
+
+// mutes returns whether the Snooze mutes the release of event to notifier.
+func (s *Snooze) mutes(event *ReleaseEvent, notifier Notifier) bool {
+	if s.Target != event.MonitorID && s.Target != event.ServiceID {
+		return false
+	}
+	options := notifier.getOptions()
+	if s.Notifier != "" && s.Notifier != options.Kind && s.Notifier != options.ID {
+		return false
+	}
+	if s.UntilVersion != "" {
+		return compareVersions(event.Version, s.UntilVersion) < 0
+	}
+	return !s.expired()
+}
+
+// mutes returns whether a Snooze mutes the release of event to notifier.
+func (s *Snoozes) mutes(event *ReleaseEvent, notifier Notifier) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.reload()
+	for index := range s.list {
+		if s.list[index].mutes(event, notifier) {
+			return true
+		}
+	}
+	return false
+}
+
+// print will print the Snooze's that haven't expired.
+func (s *Snoozes) print() {
+	fmt.Println("snoozes:")
+	for _, snooze := range s.list {
+		if snooze.expired() {
+			continue
+		}
+		fmt.Printf("  - target: %s\n", snooze.Target)
+		if snooze.Notifier != "" {
+			fmt.Printf("    notifier: %s\n", snooze.Notifier)
+		}
+		if snooze.UntilVersion != "" {
+			fmt.Printf("    until_version: %s\n", snooze.UntilVersion)
+		} else {
+			fmt.Printf("    until: %s\n", snooze.Until.Format(time.RFC3339))
+		}
+	}
+}
+
+// parseSnoozeUntil returns the Snooze for until, which is a time ("2006-01-02T15:04:05Z07:00", or "2006-01-02"
+// for the start of that day), a duration from now ("72h"), or a semantic version ("3.1.0").
+func parseSnoozeUntil(until string) (Snooze, error) {
+	if until == "" {
+		// Until it's unsnoozed.
+		return Snooze{}, nil
+	}
+	if duration, err := time.ParseDuration(until); err == nil {
+		return Snooze{Until: time.Now().Add(duration).UTC()}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, until); err == nil {
+		return Snooze{Until: t.UTC()}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", until, time.Local); err == nil {
+		return Snooze{Until: t.UTC()}, nil
+	}
+	version := strings.TrimPrefix(until, "v")
+	if _, err := semver.NewVersion(version); err != nil {
+		return Snooze{}, fmt.Errorf("-snooze-until (%s) is invalid (Use a duration '72h', a date '2006-01-02', a time '2006-01-02T15:04:05Z07:00' or a semantic version '3.1.0')", until)
+	}
+	return Snooze{UntilVersion: version}, nil
+}
+
+// snoozeCLI will act on the snooze flags, adding/removing/listing the Snooze's and exiting.
+func snoozeCLI(target *string, until *string, notifier *string, unsnooze *string, list *bool) {
+	if *target == "" && *unsnooze == "" && !*list {
+		return
+	}
+	jLog.Fatal("Snoozing is disabled ('-data' is blank)", snoozes.path == "")
+
+	if *target != "" {
+		snooze, err := parseSnoozeUntil(*until)
+		if err != nil {
+			jLog.Fatal(err.Error(), true)
+		}
+		snooze.Target, snooze.Notifier, snooze.Created = *target, *notifier, time.Now().UTC()
+		snoozes.list = append(snoozes.list, snooze)
+		err = snoozes.save()
+		jLog.Fatal(fmt.Sprintf("Failed to save the snoozes\n%s", err), err != nil)
+		fmt.Printf("Snoozed %s.\n", *target)
+	}
+	if *unsnooze != "" {
+		kept := []Snooze{}
+		for _, snooze := range snoozes.list {
+			if snooze.Target != *unsnooze || (*notifier != "" && snooze.Notifier != *notifier) {
+				kept = append(kept, snooze)
+			}
+		}
+		count := len(snoozes.list) - len(kept)
+		snoozes.list = kept
+		err := snoozes.save()
+		jLog.Fatal(fmt.Sprintf("Failed to save the snoozes\n%s", err), err != nil)
+		fmt.Printf("Removed %d snoozes.\n", count)
+	}
+	if *list {
+		snoozes.print()
+	}
+	os.Exit(0)
+}
+
+// checkIgnoreVersions will fatal if any of the ignore_versions aren't a valid regex (target is where in the config they are).
+func checkIgnoreVersions(ignoreVersions []string, target string) {
+	for index, ignore := range ignoreVersions {
+		if _, err := regexp.Compile("^(?:" + ignore + ")$"); err != nil {
+			msg := fmt.Sprintf("%s.ignore_versions[%d] (%s) is an invalid regex\n%s", target, index, ignore, err)
+			jLog.Fatal(msg, true)
+		}
+	}
+}
+
+// ignoresVersion returns whether version is in ignoreVersions (exactly, or matching the whole of one as a regex).
+func ignoresVersion(ignoreVersions []string, version string) bool {
+	for _, ignore := range ignoreVersions {
+		if ignore == version || regexCheck("^(?:"+ignore+")$", version) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSnoozeUntil(t *testing.T) {
+	if got, _ := parseSnoozeUntil("72h"); time.Until(got.Until) < 71*time.Hour || got.UntilVersion != "" {
+		t.Fatalf(`parseSnoozeUntil("72h") = %+v, want match for 72h from now`, got)
+	}
+	if got, _ := parseSnoozeUntil("2030-01-02T15:04:05Z"); !got.Until.Equal(time.Date(2030, 1, 2, 15, 4, 5, 0, time.UTC)) {
+		t.Fatalf(`parseSnoozeUntil(RFC3339) = %+v, want match for 2030-01-02T15:04:05Z`, got)
+	}
+	if got, _ := parseSnoozeUntil("2030-01-02"); !got.Until.Equal(time.Date(2030, 1, 2, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf(`parseSnoozeUntil("2030-01-02") = %+v, want match for the start of the day`, got)
+	}
+	if got, err := parseSnoozeUntil("v3.1.0"); err != nil || got.UntilVersion != "3.1.0" || !got.Until.IsZero() {
+		t.Fatalf(`parseSnoozeUntil("v3.1.0") = %+v, %v, want match for until version 3.1.0`, got, err)
+	}
+
+	// Anything else is an error (rather than a version that's never reached).
+	for _, until := range []string{"friday", "2024-6-7"} {
+		if got, err := parseSnoozeUntil(until); err == nil {
+			t.Fatalf(`parseSnoozeUntil(%q) = %+v, want match for an error`, until, got)
+		}
+	}
+}
+
+func TestSnoozesMutes(t *testing.T) {
+	dir := t.TempDir()
+	var testSnoozes Snoozes
+	testSnoozes.init(dir)
+	testSnoozes.list = []Snooze{
+		{Target: "owner/repo", Notifier: "slack", Until: time.Now().Add(time.Hour)},
+		{Target: "owner/repo", Notifier: "deploy", UntilVersion: "3.1.0"},
+		{Target: "Monitor", Until: time.Now().Add(-time.Hour)},
+	}
+	if err := testSnoozes.save(); err != nil {
+		t.Fatalf(`save() = %v, want match for <nil>`, err)
+	}
+	// The expired Snooze shouldn't be saved.
+	var reloaded Snoozes
+	reloaded.init(dir)
+	if len(reloaded.list) != 2 {
+		t.Fatalf(`len(list) = %d, want match for 2`, len(reloaded.list))
+	}
+
+	slack := &Slack{NotifyOptions: NotifyOptions{Kind: "slack"}}
+	gotify := &Gotify{NotifyOptions: NotifyOptions{Kind: "gotify"}}
+	deploy := &WebHook{NotifyOptions: NotifyOptions{Kind: "webhook", ID: "deploy"}}
+	tests := []struct {
+		notifier Notifier
+		version  string
+		want     bool
+	}{
+		{slack, "3.0.0", true},
+		{gotify, "3.0.0", false},
+		{deploy, "3.0.1", true},
+		{deploy, "3.1.0", false},
+	}
+	for index, test := range tests {
+		event := &ReleaseEvent{MonitorID: "Monitor", ServiceID: "owner/repo", Version: test.version}
+		if got := reloaded.mutes(event, test.notifier); got != test.want {
+			t.Fatalf(`%d: mutes() = %t, want match for %t`, index, got, test.want)
+		}
+	}
+}
+
+func TestIgnoresVersion(t *testing.T) {
+	ignore := []string{"3.0.0", `2\.9\..*`}
+	for version, want := range map[string]bool{"3.0.0": true, "3.0.01": false, "2.9.4": true, "12.9.4": false} {
+		if got := ignoresVersion(ignore, version); got != want {
+			t.Fatalf(`ignoresVersion(%s) = %t, want match for %t`, version, got, want)
+		}
+	}
+}
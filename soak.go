@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// setSoak will make delivery of the release of event wait until the release has been
+// the latest for the min_release_age of the notifier (or the Service), if it's a WebHook/Exec.
+func (m *Monitor) setSoak(delivery *Delivery, notifier Notifier, event *ReleaseEvent) {
+	options := notifier.getOptions()
+	// Rollbacks shouldn't wait.
+	if notifierTypes[options.Kind].messenger || event.RollbackTo != "" {
+		return
+	}
+	minReleaseAge := options.MinReleaseAge
+	if svc := m.getService(event.ServiceID); minReleaseAge == "" && svc != nil {
+		minReleaseAge = svc.MinReleaseAge
+	}
+	age, _ := time.ParseDuration(minReleaseAge)
+	if age <= 0 {
+		return
+	}
+
+	delivery.Version = event.Version
+	delivery.MinReleaseAge = minReleaseAge
+	if soaked := event.Detected.Add(age); soaked.After(delivery.Scheduled) {
+		delivery.Scheduled = soaked
+	}
+}
+
+// soak will wait until the version of delivery has been the latest of its Service for its MinReleaseAge.
+//
+// It returns false (after removing delivery from the outbox) if the version is superseded or retracted first.
+func (o *Outbox) soak(monitor *Monitor, delivery *Delivery, kind notifierType) bool {
+	age, _ := time.ParseDuration(delivery.MinReleaseAge)
+	for age > 0 {
+		svc := monitor.getService(delivery.ServiceID)
+		if svc == nil {
+			return true
+		}
+
+		var wait time.Duration
+		latest, since := svc.status.getLatest()
+		switch latest {
+		case delivery.Version:
+			wait = time.Until(since.Add(age))
+		case "":
+			// Not queried since a restart.
+			wait = time.Minute
+		default:
+			msg := fmt.Sprintf("%s (%s), Cancelled the %s of %s as it's been superseded/retracted by %s before it was %s old", delivery.ServiceID, delivery.MonitorID, kind.name, delivery.Version, latest, delivery.MinReleaseAge)
+			jLog.Info(msg, true)
+			o.mutex.Lock()
+			o.remove(outboxPending, delivery.ID)
+			o.mutex.Unlock()
+			return false
+		}
+		if wait <= 0 {
+			return true
+		}
+
+		msg := fmt.Sprintf("%s (%s), Waiting %s for %s to reach its min_release_age (%s) before sending the %s", delivery.ServiceID, delivery.MonitorID, wait.Round(time.Second), delivery.Version, delivery.MinReleaseAge, kind.name)
+		jLog.Verbose(msg, true)
+		delivery.Scheduled = time.Now().Add(wait)
+		o.mutex.Lock()
+		o.save(outboxPending, delivery)
+		o.mutex.Unlock()
+		time.Sleep(wait)
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSoak(t *testing.T) {
+	monitor := Monitor{ID: "Monitor", Service: ServiceSlice{{ID: "Service", MinReleaseAge: "1h", status: newStatus()}}}
+	svc := &monitor.Service[0]
+	var testOutbox Outbox
+	testOutbox.init(t.TempDir(), &Config{Monitor: MonitorSlice{monitor}})
+
+	// Messengers don't soak.
+	event := &ReleaseEvent{ServiceID: "Service", Version: "1.2.3", Detected: time.Now()}
+	delivery := newDelivery("slack", "Monitor", 0, "", "Service", []byte(`{}`), "0s")
+	monitor.setSoak(delivery, &Slack{NotifyOptions: NotifyOptions{Kind: "slack"}}, event)
+	if delivery.MinReleaseAge != "" {
+		t.Fatalf(`delivery.MinReleaseAge = %q, want match for ""`, delivery.MinReleaseAge)
+	}
+
+	// WebHooks soak for the min_release_age of the service (unless they have their own).
+	monitor.setSoak(delivery, &WebHook{NotifyOptions: NotifyOptions{Kind: "webhook"}}, event)
+	if delivery.Version != "1.2.3" || delivery.MinReleaseAge != "1h" || time.Until(delivery.Scheduled) < 59*time.Minute {
+		t.Fatalf(`delivery = %+v, want match for 1.2.3 scheduled in 1h`, delivery)
+	}
+	delivery = newDelivery("webhook", "Monitor", 0, "", "Service", []byte(`{}`), "0s")
+	monitor.setSoak(delivery, &WebHook{NotifyOptions: NotifyOptions{Kind: "webhook", MinReleaseAge: "2s"}}, event)
+	if delivery.MinReleaseAge != "2s" {
+		t.Fatalf(`delivery.MinReleaseAge = %q, want match for "2s"`, delivery.MinReleaseAge)
+	}
+
+	// Latest for long enough.
+	delivery.MinReleaseAge = "1ns"
+	svc.status.setLatest("1.2.3")
+	if !testOutbox.soak(&monitor, delivery, notifierTypes["webhook"]) {
+		t.Fatalf(`soak() = false, want match for true`)
+	}
+
+	// Superseded.
+	delivery.MinReleaseAge = "1h"
+	testOutbox.save(outboxPending, delivery)
+	svc.status.setLatest("1.2.4")
+	if testOutbox.soak(&monitor, delivery, notifierTypes["webhook"]) {
+		t.Fatalf(`soak() = true, want match for false`)
+	}
+	if testOutbox.exists(outboxPending, delivery.ID) {
+		t.Fatalf(`%s is still pending, want it cancelled`, delivery.ID)
+	}
+}
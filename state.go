@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// stateMaxRetractions is the number of Retraction's to keep in the State.
+const stateMaxRetractions = 100
+
+// stateMaxAnnounced is the number of versions of a Service sent to a channel to keep in the State.
+const stateMaxAnnounced = 50
+
+// State is what's kept across restarts in state.json of the data directory.
+type State struct {
+	Services    map[string]map[string]ServiceState `json:"services"`            // The state of each Service by Monitor ID and then Service ID.
+	Retractions []Retraction                       `json:"retractions"`         // The releases that were retracted, oldest first.
+	Digests     map[string][]DigestEntry           `json:"digests,omitempty"`   // The releases waiting to be sent in each digest, oldest first.
+	Announced   map[string]map[string][]string     `json:"announced,omitempty"` // The versions of each Service ID sent to each channel, oldest first.
+	path        string                             ``                           // Path of the file to persist to ("" = don't persist).
+	mutex       sync.Mutex                         ``                           // Lock for the vars and the file.
+}
+
+// ServiceState is the state of a Service.
+type ServiceState struct {
+	Version   string      `json:"version"`             // The version the Service is on.
+	Detected  time.Time   `json:"detected"`            // When Version was found.
+	Candidate *Candidate  `json:"candidate,omitempty"` // The new version waiting on its confirmations.
+	Releases  []time.Time `json:"releases,omitempty"`  // When its last releases were made (for service.adaptive).
+}
+
+// Candidate is a new version waiting on its confirmations.
+type Candidate struct {
+	Version       string    `json:"version"`       // "1.2.4"
+	Confirmations uint      `json:"confirmations"` // Number of queries in a row it's been found on.
+	Required      uint      `json:"required"`      // Number of queries in a row it has to be found on (service.confirmations).
+	Since         time.Time `json:"since"`         // When it was first found.
+}
+
+// Retraction is a release that was retracted (deleted, or the latest went backwards).
+type Retraction struct {
+	MonitorID  string    `json:"monitor_id"`  // "SERVICE_NAME"
+	ServiceID  string    `json:"service_id"`  // "owner/repo"
+	Version    string    `json:"version"`     // The version that was retracted.
+	RollbackTo string    `json:"rollback_to"` // The version that's the latest again.
+	Detected   time.Time `json:"detected"`    // When the retraction was found.
+}
+
+// init will initialise the State to persist to state.json in dir, loading what's already there.
+//
+// A blank dir will only keep the State in memory.
+func (s *State) init(dir string) {
+	if dir == "" {
+		return
+	}
+	s.path = filepath.Join(dir, "state.json")
+
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			msg := fmt.Sprintf("Failed to read the state from '%s'\n%s", s.path, err)
+			jLog.Error(msg, true)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		msg := fmt.Sprintf("Failed to parse the state in '%s'\n%s", s.path, err)
+		jLog.Error(msg, true)
+	}
+}
+
+// save will write the State to its file. The caller must hold the lock.
+func (s *State) save() {
+	if s.path == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(s.path), 0o755); err == nil {
+			err = writeFileAtomic(s.path, data)
+		}
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Failed to save the state to '%s'\n%s", s.path, err)
+		jLog.Error(msg, true)
+	}
+}
+
+// setService will set the ServiceState of the Service with ID serviceID in the Monitor with ID monitorID
+// (saving it if it changed).
+func (s *State) setService(monitorID string, serviceID string, serviceState ServiceState) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if current, exists := s.Services[monitorID][serviceID]; exists && current.equals(serviceState) {
+		return
+	}
+
+	if s.Services == nil {
+		s.Services = map[string]map[string]ServiceState{}
+	}
+	if s.Services[monitorID] == nil {
+		s.Services[monitorID] = map[string]ServiceState{}
+	}
+	s.Services[monitorID][serviceID] = serviceState
+	s.save()
+}
+
+// equals returns whether the ServiceState is the same as other.
+func (s ServiceState) equals(other ServiceState) bool {
+	if s.Version != other.Version || !s.Detected.Equal(other.Detected) || (s.Candidate == nil) != (other.Candidate == nil) ||
+		len(s.Releases) != len(other.Releases) {
+		return false
+	}
+	for i := range s.Releases {
+		if !s.Releases[i].Equal(other.Releases[i]) {
+			return false
+		}
+	}
+	return s.Candidate == nil || (s.Candidate.Version == other.Candidate.Version &&
+		s.Candidate.Confirmations == other.Candidate.Confirmations &&
+		s.Candidate.Required == other.Candidate.Required &&
+		s.Candidate.Since.Equal(other.Candidate.Since))
+}
+
+// restore will give each Service of monitors what it needs back from the State (the times of its releases).
+func (s *State) restore(monitors MonitorSlice) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for monitorIndex := range monitors {
+		for serviceIndex := range monitors[monitorIndex].Service {
+			service := &monitors[monitorIndex].Service[serviceIndex]
+			if serviceState, exists := s.Services[monitors[monitorIndex].ID][service.ID]; exists {
+				service.status.setReleases(serviceState.Releases)
+			}
+		}
+	}
+}
+
+// print will print the ServiceState of each Service of monitors.
+func (s *State) print(monitors MonitorSlice) {
+	for _, monitor := range monitors {
+		fmt.Printf("%s:\n", monitor.ID)
+		for _, service := range monitor.Service {
+			fmt.Printf("  - id: %s\n", service.ID)
+			serviceState, exists := s.Services[monitor.ID][service.ID]
+			if !exists || serviceState.Version == "" {
+				fmt.Println("    version: (not found yet)")
+				continue
+			}
+			fmt.Printf("    version: %s\n", serviceState.Version)
+			fmt.Printf("    detected: %s\n", serviceState.Detected.Format(time.RFC3339))
+			if candidate := serviceState.Candidate; candidate != nil {
+				fmt.Println("    candidate:")
+				fmt.Printf("      version: %s\n", candidate.Version)
+				fmt.Printf("      confirmations: %d/%d\n", candidate.Confirmations, candidate.Required)
+				fmt.Printf("      since: %s\n", candidate.Since.Format(time.RFC3339))
+			}
+		}
+	}
+}
+
+// statusCLI will act on the 'status' flag, printing the state of the services and exiting.
+func statusCLI(flag *bool, config *Config) {
+	if !*flag {
+		return
+	}
+	jLog.Fatal("The state is disabled ('-data' is blank)", state.path == "")
+
+	state.print(config.Monitor)
+	os.Exit(0)
+}
+
+// addRetraction will record the retraction of the release of event.
+func (s *State) addRetraction(event *ReleaseEvent) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Retractions = append(s.Retractions, Retraction{
+		MonitorID:  event.MonitorID,
+		ServiceID:  event.ServiceID,
+		Version:    event.Version,
+		RollbackTo: event.RollbackTo,
+		Detected:   time.Now().UTC(),
+	})
+	if len(s.Retractions) > stateMaxRetractions {
+		s.Retractions = s.Retractions[len(s.Retractions)-stateMaxRetractions:]
+	}
+	s.save()
+}
+
+// addDigest will add entry to the digest with key.
+func (s *State) addDigest(key string, entry DigestEntry) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.Digests == nil {
+		s.Digests = map[string][]DigestEntry{}
+	}
+	s.Digests[key] = append(s.Digests[key], entry)
+	s.save()
+}
+
+// getDigest returns the entries of the digest with key.
+func (s *State) getDigest(key string) []DigestEntry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]DigestEntry{}, s.Digests[key]...)
+}
+
+// takeDigest returns the entries of the digest with key, removing them from the State.
+func (s *State) takeDigest(key string) []DigestEntry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entries := s.Digests[key]
+	if len(entries) == 0 {
+		return nil
+	}
+	delete(s.Digests, key)
+	s.save()
+	return entries
+}
+
+// announced returns whether version of the Service with ID serviceID has been sent to the channel key.
+func (s *State) announced(key string, serviceID string, version string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, announced := range s.Announced[key][serviceID] {
+		if announced == version {
+			return true
+		}
+	}
+	return false
+}
+
+// announce will record that version of the Service with ID serviceID has been sent to the channel key.
+func (s *State) announce(key string, serviceID string, version string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, announced := range s.Announced[key][serviceID] {
+		if announced == version {
+			return
+		}
+	}
+
+	if s.Announced == nil {
+		s.Announced = map[string]map[string][]string{}
+	}
+	if s.Announced[key] == nil {
+		s.Announced[key] = map[string][]string{}
+	}
+	versions := append(s.Announced[key][serviceID], version)
+	if len(versions) > stateMaxAnnounced {
+		versions = versions[len(versions)-stateMaxAnnounced:]
+	}
+	s.Announced[key][serviceID] = versions
+	s.save()
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestStateRetractions(t *testing.T) {
+	dir := t.TempDir()
+	var testState State
+	testState.init(dir)
+	testState.addRetraction(&ReleaseEvent{MonitorID: "Monitor", ServiceID: "owner/repo", Version: "1.2.3", RollbackTo: "1.2.2"})
+
+	// It should be loaded again after a restart.
+	var restarted State
+	restarted.init(dir)
+	if len(restarted.Retractions) != 1 || restarted.Retractions[0].Version != "1.2.3" || restarted.Retractions[0].RollbackTo != "1.2.2" {
+		t.Fatalf(`Retractions = %+v, want match for 1.2.3 rolled back to 1.2.2`, restarted.Retractions)
+	}
+
+	// Only the latest are kept.
+	for i := 0; i < stateMaxRetractions; i++ {
+		restarted.addRetraction(&ReleaseEvent{Version: "2.0.0"})
+	}
+	if len(restarted.Retractions) != stateMaxRetractions || restarted.Retractions[0].Version != "2.0.0" {
+		t.Fatalf(`len(Retractions) = %d, want match for %d`, len(restarted.Retractions), stateMaxRetractions)
+	}
+}
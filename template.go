@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/coreos/go-semver/semver"
+)
+
+// templateFuncs are the helper functions available in message templates.
+var templateFuncs = template.FuncMap{
+	"semverMajor":     semverMajor,
+	"trimPrefix":      trimPrefix,
+	"truncate":        truncate,
+	"markdownToSlack": markdownToSlack,
+}
+
+// templateLegacyVar matches the old '${var}' style of template variable.
+var templateLegacyVar = regexp.MustCompile(`\$\{([a-z_]+)\}`)
+
+// getTemplateData returns the variables available in message templates about event.
+func (e *ReleaseEvent) getTemplateData() map[string]interface{} {
+	var assetURLs []string
+	for _, asset := range e.Release.Assets {
+		assetURLs = append(assetURLs, asset.BrowserDownloadURL)
+	}
+	published := e.Release.PublishedAt
+	if published.IsZero() {
+		published = e.Detected
+	}
+
+	return map[string]interface{}{
+		"monitor_id":       e.MonitorID,
+		"service_id":       e.ServiceID,
+		"service_url":      e.ServiceURL,
+		"version":          e.Version,
+		"previous_version": e.PreviousVersion,
+		"tag":              e.getTag(),
+		"release_title":    valueOrValueString(e.Release.Name, e.getTag()),
+		"release_notes":    e.Notes,
+		"notes":            sanitiseNotes(e.Notes),
+		"release_url":      e.getReleaseURL(),
+		"published":        published,
+		"asset_urls":       assetURLs,
+		"change_level":     getChangeLevel(e.PreviousVersion, e.Version),
+		"compare":          e.Compare,
+		"severity":         e.Severity,
+		"vulnerabilities":  e.Vulnerabilities,
+		"signature":        e.Signature,
+		"rollback_to":      e.RollbackTo,
+	}
+}
+
+// parseTemplate parses text as a message template.
+//
+// '${var}' is converted to '{{ .var }}' for every known var, so the old syntax keeps working.
+func parseTemplate(text string) (*template.Template, error) {
+	known := (&ReleaseEvent{}).getTemplateData()
+	text = templateLegacyVar.ReplaceAllStringFunc(text, func(match string) string {
+		name := templateLegacyVar.FindStringSubmatch(match)[1]
+		if _, exists := known[name]; exists {
+			return "{{ ." + name + " }}"
+		}
+		return match
+	})
+	return template.New("message").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// checkTemplate will fatal if text isn't a valid message template (target is where in the config it is).
+func checkTemplate(text string, target string) {
+	if _, err := parseTemplate(text); err != nil {
+		msg := fmt.Sprintf("%s (%s) is an invalid template\n%s", target, text, err)
+		jLog.Fatal(msg, true)
+	}
+}
+
+// templateUses returns whether the message template text uses any of the vars names.
+func templateUses(text string, names ...string) bool {
+	tmpl, err := parseTemplate(text)
+	if err != nil || tmpl.Tree == nil {
+		return false
+	}
+	parsed := tmpl.Tree.Root.String()
+	for _, name := range names {
+		if regexp.MustCompile(`\.` + name + `\b`).MatchString(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderTemplate returns text rendered as a message template with data (from ReleaseEvent.getTemplateData).
+func renderTemplate(text string, data map[string]interface{}) (string, error) {
+	// Skip the work when there's nothing to render.
+	if !strings.Contains(text, "{{") && !strings.Contains(text, "${") {
+		return text, nil
+	}
+
+	tmpl, err := parseTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", err
+	}
+	return rendered.String(), nil
+}
+
+// getChangeLevel returns the level of the semantic version change from previousVersion to version.
+//
+// "major"/"minor"/"patch"/"prerelease" ("" if either isn't a semantic version or this is the first version).
+func getChangeLevel(previousVersion string, version string) string {
+	previous, err := semver.NewVersion(strings.TrimPrefix(previousVersion, "v"))
+	if err != nil {
+		return ""
+	}
+	current, err := semver.NewVersion(strings.TrimPrefix(version, "v"))
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case current.Major != previous.Major:
+		return "major"
+	case current.Minor != previous.Minor:
+		return "minor"
+	case current.Patch != previous.Patch:
+		return "patch"
+	case current.PreRelease != previous.PreRelease:
+		return "prerelease"
+	}
+	return ""
+}
+
+// semverMajor returns the major number of the semantic version.
+//
+// e.g. {{ semverMajor .version }}
+func semverMajor(version string) (int64, error) {
+	parsed, err := semver.NewVersion(strings.TrimPrefix(version, "v"))
+	if err != nil {
+		return 0, err
+	}
+	return parsed.Major, nil
+}
+
+// trimPrefix returns text without prefix.
+//
+// e.g. {{ .tag | trimPrefix "v" }}
+func trimPrefix(prefix string, text string) string {
+	return strings.TrimPrefix(text, prefix)
+}
+
+// truncate returns text cut down to length characters (ending with '…' when cut).
+//
+// e.g. {{ .release_notes | truncate 500 }}
+func truncate(length int, text string) string {
+	runes := []rune(text)
+	if length < 1 || len(runes) <= length {
+		return text
+	}
+	return string(runes[:length-1]) + "…"
+}
+
+var (
+	markdownHeading    = regexp.MustCompile(`(?m)^#{1,6}\s+(.+?)\s*#*$`)
+	markdownBold       = regexp.MustCompile(`(\*\*|__)(.+?)(\*\*|__)`)
+	markdownStrike     = regexp.MustCompile(`~~(.+?)~~`)
+	markdownItalic     = regexp.MustCompile(`(^|[^*\w])\*([^*\s][^*]*?)\*`)
+	markdownLink       = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	markdownListMarker = regexp.MustCompile(`(?m)^(\s*)[*+-]\s+`)
+	slackBold          = "\x00"
+)
+
+// markdownToSlack converts (GitHub flavoured) markdown to Slack mrkdwn.
+//
+// e.g. {{ .release_notes | markdownToSlack }}
+func markdownToSlack(text string) string {
+	text = markdownLink.ReplaceAllString(text, "<$2|$1>")
+	text = markdownListMarker.ReplaceAllString(text, "$1• ")
+	// Bold is '*' in Slack, so hold it in a placeholder until italics ('*' in markdown) are done.
+	text = markdownHeading.ReplaceAllString(text, slackBold+"$1"+slackBold)
+	text = markdownBold.ReplaceAllString(text, slackBold+"$2"+slackBold)
+	text = markdownItalic.ReplaceAllString(text, "${1}_${2}_")
+	text = markdownStrike.ReplaceAllString(text, "~$1~")
+	return strings.ReplaceAll(text, slackBold, "*")
+}
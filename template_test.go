@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	event := &ReleaseEvent{
+		MonitorID:       "Monitor",
+		ServiceID:       "owner/repo",
+		ServiceURL:      "https://github.com/owner/repo",
+		PreviousVersion: "1.2.3",
+		Version:         "2.0.0",
+		Release: GitHubRelease{
+			TagName: "v2.0.0",
+			Body:    "Lots of changes",
+			Assets:  []GitHubAsset{{Name: "app.tar.gz", BrowserDownloadURL: "https://example.com/app.tar.gz"}},
+		},
+		Notes: "Lots of changes",
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		// The old syntax should keep working (and leave unknown vars alone).
+		{"<${service_url}|${service_id}> - ${version} released ${unknown}", "<https://github.com/owner/repo|owner/repo> - 2.0.0 released ${unknown}"},
+		{"{{ .previous_version }} -> {{ .version }} ({{ .change_level }})", "1.2.3 -> 2.0.0 (major)"},
+		{"{{ .tag | trimPrefix \"v\" }} is v{{ semverMajor .version }}", "2.0.0 is v2"},
+		{"{{ .release_notes | truncate 5 }}", "Lots…"},
+		{"{{ .release_title }}{{ range .asset_urls }} {{ . }}{{ end }}", "v2.0.0 https://example.com/app.tar.gz"},
+	}
+	for _, test := range tests {
+		got, err := renderTemplate(test.text, event.getTemplateData())
+		if err != nil || got != test.want {
+			t.Fatalf(`renderTemplate(%q) = %q, %v - want match for %q, <nil>`, test.text, got, err, test.want)
+		}
+	}
+
+	// Unknown vars should fail with the new syntax.
+	if _, err := renderTemplate("{{ .unknown }}", event.getTemplateData()); err == nil {
+		t.Fatalf(`renderTemplate("{{ .unknown }}") = %v, want an error`, err)
+	}
+}
+
+func TestGetChangeLevel(t *testing.T) {
+	tests := []struct {
+		previous string
+		version  string
+		want     string
+	}{
+		{"1.2.3", "2.0.0", "major"},
+		{"1.2.3", "1.3.0", "minor"},
+		{"v1.2.3", "v1.2.4", "patch"},
+		{"1.2.3-rc1", "1.2.3", "prerelease"},
+		{"", "1.2.3", ""},
+		{"latest", "1.2.3", ""},
+	}
+	for _, test := range tests {
+		if got := getChangeLevel(test.previous, test.version); got != test.want {
+			t.Fatalf(`getChangeLevel(%q, %q) = %q, want match for %q`, test.previous, test.version, got, test.want)
+		}
+	}
+}
+
+func TestMarkdownToSlack(t *testing.T) {
+	markdown := "## Changes\n- **Fixed** a [bug](https://example.com/1)\n* *Faster* ~~slow~~ start"
+	want := "*Changes*\n• *Fixed* a <https://example.com/1|bug>\n• _Faster_ ~slow~ start"
+	if got := markdownToSlack(markdown); got != want {
+		t.Fatalf("markdownToSlack() = %q, want match for %q", got, want)
+	}
+}
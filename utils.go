@@ -1,6 +1,8 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
 	"strings"
 )
 
@@ -52,3 +54,12 @@ func valueOrValueUInt(a uint, b uint) uint {
 	}
 	return a
 }
+
+// writeFileAtomic writes data to the file at path via a temp file that's renamed over it,
+// so a crash can't leave a partial file (and nothing reading it can see one).
+func writeFileAtomic(path string, data []byte) error {
+	if err := ioutil.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -3,16 +3,12 @@ package main
 import (
 	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha1"
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -21,13 +17,14 @@ type WebHookSlice []WebHook
 
 // WebHook is a WebHook to send.
 type WebHook struct {
-	Type              string `yaml:"type"`                          // "github"/"url"
-	URL               string `yaml:"url"`                           // "https://example.com"
-	Secret            string `yaml:"secret,omitempty"`              // "SECRET"
-	DesiredStatusCode int    `yaml:"desired_status_code,omitempty"` // e.g. 202
-	Delay             string `yaml:"delay,omitempty"`               // The delay before sending the WebHook.
-	MaxTries          uint   `yaml:"max_tries,omitempty"`           // Number of times to attempt sending the WebHook if the desired status code is not received.
-	SilentFails       string `yaml:"silent_fails,omitempty"`        // Whether to notify if this WebHook fails MaxTries times.
+	Type              string           `yaml:"type"`                          // "github"/"gitlab"/"gitea"
+	URL               string           `yaml:"url"`                           // "https://example.com"
+	Secret            string           `yaml:"secret,omitempty"`              // "SECRET"
+	Secrets           []string         `yaml:"secrets,omitempty"`             // Additional secrets to sign with (signing:standard), for secret rotation.
+	Signing           string           `yaml:"signing,omitempty"`             // "" = sign as Type / "standard" = Standard Webhooks (https://www.standardwebhooks.com).
+	DesiredStatusCode int              `yaml:"desired_status_code,omitempty"` // e.g. 202
+	Rollback          string           `yaml:"rollback,omitempty"`            // default - false = Don't also send this WebHook (as a deletion) when a release is retracted.
+	NotifyOptions     `yaml:",inline"` // Delay, MaxTries, Retry, SilentFails, ...
 }
 
 // UnmarshalYAML allows handling of a dict as well as a list of dicts.
@@ -66,15 +63,18 @@ func (w *WebHook) setDefaults(defaults Defaults) {
 	// DesiredStatusCode
 	w.DesiredStatusCode = valueOrValueInt(w.DesiredStatusCode, defaults.WebHook.DesiredStatusCode)
 
-	// Delay
-	w.Delay = valueOrValueString(w.Delay, defaults.WebHook.Delay)
+	// Delay, MaxTries, Retry, SilentFails
+	w.NotifyOptions.setDefaults(defaults.WebHook.NotifyOptions)
 
-	// MaxTries
-	w.MaxTries = valueOrValueUInt(w.MaxTries, defaults.WebHook.MaxTries)
+	// Rollback
+	w.Rollback = valueOrValueString(w.Rollback, defaults.WebHook.Rollback)
+	w.Rollback = stringBool(w.Rollback, "", "", false)
 
-	// SilentFails
-	w.SilentFails = valueOrValueString(w.SilentFails, defaults.WebHook.SilentFails)
-	w.SilentFails = stringBool(w.SilentFails, "", "", false)
+	// Type
+	w.Type = strings.ToLower(valueOrValueString(w.Type, defaults.WebHook.Type))
+
+	// Signing
+	w.Signing = strings.ToLower(valueOrValueString(w.Signing, defaults.WebHook.Signing))
 }
 
 // checkValues will check the variables for all of this Monitor's WebHook recipients.
@@ -90,25 +90,63 @@ func (w *WebHook) checkValues(monitorID string, index int, loneService bool) {
 	if !loneService {
 		target = fmt.Sprintf("%s[%d]", monitorID, index)
 	}
+	w.validate(target)
+}
 
-	// Delay
-	if w.Delay != "" {
-		// Default to seconds when an integer is provided
-		if _, err := strconv.Atoi(w.Delay); err == nil {
-			w.Delay += "s"
+// validate will check that the variables are valid for this WebHook recipient.
+func (w *WebHook) validate(target string) {
+	w.NotifyOptions.validate(target)
+
+	// Type
+	switch w.Type {
+	case "", "github", "gitlab", "gitea":
+	default:
+		msg := fmt.Sprintf("%s.type (%s) is invalid (Use 'github', 'gitlab' or 'gitea')", target, w.Type)
+		jLog.Fatal(msg, true)
+	}
+
+	// Signing
+	switch w.Signing {
+	case "":
+	case "standard":
+		if len(w.getSecrets()) == 0 {
+			msg := fmt.Sprintf("%s.secret (or secrets) is required with signing:standard", target)
+			jLog.Fatal(msg, target != "defaults")
 		}
-		if _, err := time.ParseDuration(w.Delay); err != nil {
-			msg := fmt.Sprintf("%s.delay (%s) is invalid (Use 'AhBmCs' duration format)", target, w.Delay)
-			jLog.Fatal(msg, true)
+		for _, secret := range w.getSecrets() {
+			if _, err := decodeStandardSecret(secret); err != nil {
+				msg := fmt.Sprintf("%s.secret (%s) is invalid for signing:standard ('whsec_' secrets must be followed by base64)\n%s", target, secret, err)
+				jLog.Fatal(msg, true)
+			}
 		}
+	default:
+		msg := fmt.Sprintf("%s.signing (%s) is invalid (Use 'standard' or leave it blank)", target, w.Signing)
+		jLog.Fatal(msg, true)
 	}
 }
 
-// WebHookGitHub is the WebHook payload to emulate GitHub.
-type WebHookGitHub struct {
-	Ref    string `json:"ref"`    // "refs/heads/master"
-	Before string `json:"before"` // "randAlphaNumericLower(40)"
-	After  string `json:"after"`  // "randAlphaNumericLower(40)"
+// getTarget returns the URL of this WebHook.
+func (w *WebHook) getTarget() string {
+	return w.URL
+}
+
+// print will print this WebHook as a list element.
+func (w *WebHook) print(prefix string) {
+	fmt.Printf("%s- type: %s\n", prefix, w.Type)
+	fmt.Printf("%s  url: '%s'\n", prefix, w.URL)
+	fmt.Printf("%s  secret: '%s'\n", prefix, w.Secret)
+	if len(w.Secrets) != 0 {
+		fmt.Printf("%s  secrets:\n", prefix)
+		for _, secret := range w.Secrets {
+			fmt.Printf("%s    - '%s'\n", prefix, secret)
+		}
+	}
+	if w.Signing != "" {
+		fmt.Printf("%s  signing: %s\n", prefix, w.Signing)
+	}
+	fmt.Printf("%s  desired_status_code: %d\n", prefix, w.DesiredStatusCode)
+	fmt.Printf("%s  rollback: %s\n", prefix, w.Rollback)
+	w.NotifyOptions.print(prefix + "  ")
 }
 
 // randString will make a random string of length n with alphabet.
@@ -134,86 +172,41 @@ func randAlphaNumericLower(n int) string {
 	return randString(n, alphanumericLower)
 }
 
-// send will send every WebHook in this WebHookSlice with a delay between each webhook.
-func (w *WebHookSlice) send(monitorID string, serviceID string, gotifys GotifySlice, gotifyDefaults Gotify, slacks SlackSlice) {
-	for index := range *w {
-		go func() {
-			index := index                    // Create new instance for the goroutine.
-			triesLeft := (*w)[index].MaxTries // Number of times to send WebHook (until w.DesiredStatusCode received).
-
-			// Delay sending the Slack message by the defined interval.
-			sleepTime, _ := time.ParseDuration((*w)[index].Delay)
-			msg := fmt.Sprintf("%s (%s), Sleeping for %s before sending the WebHook", serviceID, monitorID, (*w)[index].Delay)
-			jLog.Info(msg, (sleepTime != 0))
-			time.Sleep(sleepTime)
-
-			for {
-				err := (*w)[index].send(monitorID, serviceID)
-
-				// SUCCESS!
-				if err == nil {
-					break
-				}
-
-				// FAIL!
-				jLog.Error(err.Error(), true)
-				triesLeft--
-
-				// Give up after MaxTries.
-				if triesLeft == 0 {
-					msg := fmt.Sprintf("%s (%s), Failed %d times to send a WebHook to %s", serviceID, monitorID, (*w)[index].MaxTries, (*w)[index].URL)
-					if (*w)[index].SilentFails == "n" {
-						svc := Service{
-							ID: serviceID,
-						}
-						slacks.send(monitorID, &svc, msg)
-						gotifys.send(monitorID, &svc, "WebHook fail", msg, gotifyDefaults)
-					}
-					msg = fmt.Sprintf("%s (%s), %s", serviceID, monitorID, msg)
-					jLog.Error(msg, true)
-					break
-				}
-				// Space out retries.
-				time.Sleep(10 * time.Second)
-			}
-		}()
-		// Space out WebHooks.
-		time.Sleep(3 * time.Second)
-	}
+const hexLower = "0123456789abcdef"
+
+// randHexLower will return a random hexadecimal (lowercase) string of length n.
+func randHexLower(n int) string {
+	return randString(n, hexLower)
 }
 
-// send will send a WebHook to the WebHook URL with the body SHA1 and SHA256 encrypted with WebHook.Secret.
-// It also simulates other GitHub headers and returns when an error is encountered.
-func (w *WebHook) send(monitorID string, serviceID string) error {
-	// GitHub style payload.
-	payload, err := json.Marshal(WebHookGitHub{Ref: "refs/heads/master", Before: randAlphaNumericLower(40), After: randAlphaNumericLower(40)})
-	if err != nil {
-		return err
-	}
+// randDeliveryID will return a random UUID style delivery ID.
+func randDeliveryID() string {
+	return fmt.Sprintf("%s-%s-%s-%s-%s", randHexLower(8), randHexLower(4), randHexLower(4), randHexLower(4), randHexLower(12))
+}
+
+// render returns the payload of the WebHook.Type being emulated regarding event.
+//
+// WebHooks aren't sent for custom messages, so title and message are ignored.
+func (w *WebHook) render(event *ReleaseEvent, title string, message string, defaults Defaults) ([]byte, error) {
+	return w.getPayload(event)
+}
+
+// send will send a WebHook to the WebHook URL with the payload of delivery and the headers of the
+// WebHook.Type being emulated (or WebHook.Signing) and returns when an error is encountered.
+func (w *WebHook) send(delivery *Delivery) error {
+	monitorID, serviceID, deliveryID, payload := delivery.MonitorID, delivery.ServiceID, delivery.ID, []byte(delivery.Payload)
 	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(payload))
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if w.Signing == "standard" {
+		w.setStandardHeaders(req, deliveryID, time.Now(), payload)
+	} else {
+		w.setHeaders(req, deliveryID, payload)
+	}
 
-	// GitHub style headers.
-	req.Header.Set("X-GitHub-Event", "push")
-	req.Header.Set("X-GitHub-Hook-ID", randNumeric(9))
-	req.Header.Set("X-GitHub-Delivery", fmt.Sprintf("%s-%s-%s-%s-%s", randAlphaNumericLower(8), randAlphaNumericLower(4), randAlphaNumericLower(4), randAlphaNumericLower(4), randAlphaNumericLower(12)))
-	req.Header.Set("X-GitHub-Hook-Installation-Target-ID", randNumeric(9))
-	req.Header.Set("X-GitHub-Hook-Installation-Target-Type", "repository")
-
-	// X-Hub-Signature-256.
-	hash := hmac.New(sha256.New, []byte(w.Secret))
-	hash.Write(payload)
-	req.Header.Set("X-Hub-Signature-256", fmt.Sprintf("sha256=%s", hex.EncodeToString(hash.Sum(nil))))
-
-	// X-Hub-Signature.
-	hash = hmac.New(sha1.New, []byte(w.Secret))
-	hash.Write(payload)
-	req.Header.Set("X-Hub-Signature", fmt.Sprintf("sha1=%s", hex.EncodeToString(hash.Sum(nil))))
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), w.Retry.getTimeout())
 	req = req.WithContext(ctx)
 	defer cancel()
 
@@ -242,5 +235,6 @@ func (w *WebHook) send(monitorID string, serviceID string) error {
 		desiredStatusCode = "2XX"
 	}
 
-	return fmt.Errorf("%s (%s), WebHook didn't %s:\n%s\n%s", serviceID, monitorID, desiredStatusCode, resp.Status, body)
+	err = fmt.Errorf("%s (%s), WebHook didn't %s:\n%s\n%s", serviceID, monitorID, desiredStatusCode, resp.Status, body)
+	return newResponseError(resp, err)
 }
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getPayload returns the JSON payload of the WebHook.Type being emulated for the release of event.
+func (w *WebHook) getPayload(event *ReleaseEvent) ([]byte, error) {
+	switch w.Type {
+	case "gitlab":
+		return json.Marshal(newWebHookGitLab(event))
+	case "gitea":
+		return json.Marshal(newWebHookGitea(event))
+	default:
+		return json.Marshal(newWebHookGitHub(event))
+	}
+}
+
+// setHeaders sets the event, delivery and signature headers of the WebHook.Type being emulated.
+func (w *WebHook) setHeaders(req *http.Request, deliveryID string, payload []byte) {
+	switch w.Type {
+	case "gitlab":
+		req.Header.Set("X-Gitlab-Event", "Tag Push Hook")
+		req.Header.Set("X-Gitlab-Event-UUID", deliveryID)
+		// GitLab sends the secret as-is rather than signing the payload.
+		req.Header.Set("X-Gitlab-Token", w.Secret)
+	case "gitea":
+		req.Header.Set("X-Gitea-Event", "push")
+		req.Header.Set("X-Gitea-Event-Type", "push")
+		req.Header.Set("X-Gitea-Delivery", deliveryID)
+
+		// X-Gitea-Signature.
+		hash := hmac.New(sha256.New, []byte(w.Secret))
+		hash.Write(payload)
+		req.Header.Set("X-Gitea-Signature", hex.EncodeToString(hash.Sum(nil)))
+	default:
+		req.Header.Set("X-GitHub-Event", "release")
+		req.Header.Set("X-GitHub-Hook-ID", randNumeric(9))
+		req.Header.Set("X-GitHub-Delivery", deliveryID)
+		req.Header.Set("X-GitHub-Hook-Installation-Target-ID", randNumeric(9))
+		req.Header.Set("X-GitHub-Hook-Installation-Target-Type", "repository")
+
+		// X-Hub-Signature-256.
+		hash := hmac.New(sha256.New, []byte(w.Secret))
+		hash.Write(payload)
+		req.Header.Set("X-Hub-Signature-256", fmt.Sprintf("sha256=%s", hex.EncodeToString(hash.Sum(nil))))
+
+		// X-Hub-Signature.
+		hash = hmac.New(sha1.New, []byte(w.Secret))
+		hash.Write(payload)
+		req.Header.Set("X-Hub-Signature", fmt.Sprintf("sha1=%s", hex.EncodeToString(hash.Sum(nil))))
+	}
+}
+
+// getSecrets returns WebHook.Secret followed by WebHook.Secrets, skipping blanks.
+func (w *WebHook) getSecrets() []string {
+	var secrets []string
+	for _, secret := range append([]string{w.Secret}, w.Secrets...) {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+// decodeStandardSecret returns the key of a Standard Webhooks secret.
+//
+// 'whsec_' secrets are base64 decoded, other secrets are used as-is.
+func decodeStandardSecret(secret string) ([]byte, error) {
+	if strings.HasPrefix(secret, "whsec_") {
+		return base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	}
+	return []byte(secret), nil
+}
+
+// setStandardHeaders sets the webhook-id, webhook-timestamp and webhook-signature headers
+// of the Standard Webhooks spec (https://www.standardwebhooks.com).
+//
+// The payload is signed with every secret so that secrets can be rotated.
+func (w *WebHook) setStandardHeaders(req *http.Request, deliveryID string, timestamp time.Time, payload []byte) {
+	unixTimestamp := strconv.FormatInt(timestamp.Unix(), 10)
+	req.Header.Set("webhook-id", deliveryID)
+	req.Header.Set("webhook-timestamp", unixTimestamp)
+
+	signedContent := []byte(fmt.Sprintf("%s.%s.%s", deliveryID, unixTimestamp, payload))
+	var signatures []string
+	for _, secret := range w.getSecrets() {
+		key, _ := decodeStandardSecret(secret)
+		hash := hmac.New(sha256.New, key)
+		hash.Write(signedContent)
+		signatures = append(signatures, "v1,"+base64.StdEncoding.EncodeToString(hash.Sum(nil)))
+	}
+	req.Header.Set("webhook-signature", strings.Join(signatures, " "))
+}
+
+// getRepositoryName returns the "repo" of "owner/repo".
+func getRepositoryName(fullName string) string {
+	if strings.Contains(fullName, "/") {
+		return strings.SplitN(fullName, "/", 2)[1]
+	}
+	return fullName
+}
+
+// WebHookGitHub is the WebHook payload to emulate a GitHub 'release' event.
+type WebHookGitHub struct {
+	Action     string                  `json:"action"`                // "published" ("deleted" when the release was retracted)
+	Release    WebHookGitHubRelease    `json:"release"`               // The release that was published.
+	Repository WebHookGitHubRepository `json:"repository"`            // The repository the release belongs to.
+	Compare    *CompareSummary         `json:"compare,omitempty"`     // What changed since the previous version (service.compare).
+	RollbackTo string                  `json:"rollback_to,omitempty"` // The version that's the latest again when the release was retracted.
+}
+
+// WebHookGitHubRelease is the release of a GitHub 'release' event.
+type WebHookGitHubRelease struct {
+	TagName    string `json:"tag_name"`   // "v1.2.3"
+	Name       string `json:"name"`       // "v1.2.3"
+	Body       string `json:"body"`       // The release notes.
+	HTMLURL    string `json:"html_url"`   // "https://github.com/owner/repo/releases/tag/v1.2.3"
+	Draft      bool   `json:"draft"`      // false
+	Prerelease bool   `json:"prerelease"` // false
+}
+
+// WebHookGitHubRepository is the repository of a GitHub event.
+type WebHookGitHubRepository struct {
+	Name     string `json:"name"`      // "repo"
+	FullName string `json:"full_name"` // "owner/repo"
+	HTMLURL  string `json:"html_url"`  // "https://github.com/owner/repo"
+}
+
+// newWebHookGitHub returns the GitHub 'release' event payload for the release of event.
+func newWebHookGitHub(event *ReleaseEvent) WebHookGitHub {
+	action := "published"
+	if event.RollbackTo != "" {
+		action = "deleted"
+	}
+
+	return WebHookGitHub{
+		Action: action,
+		Release: WebHookGitHubRelease{
+			TagName: event.getTag(),
+			Name:    valueOrValueString(event.Release.Name, event.Version),
+			Body:    event.Notes,
+			HTMLURL: event.getReleaseURL(),
+		},
+		Repository: WebHookGitHubRepository{
+			Name:     getRepositoryName(event.Repository),
+			FullName: event.Repository,
+			HTMLURL:  event.ServiceURL,
+		},
+		Compare:    event.Compare,
+		RollbackTo: event.RollbackTo,
+	}
+}
+
+// WebHookGitLab is the WebHook payload to emulate a GitLab 'Tag Push Hook'.
+type WebHookGitLab struct {
+	ObjectKind        string               `json:"object_kind"`           // "tag_push"
+	EventName         string               `json:"event_name"`            // "tag_push"
+	Before            string               `json:"before"`                // "0000000000000000000000000000000000000000"
+	After             string               `json:"after"`                 // "randHexLower(40)"
+	Ref               string               `json:"ref"`                   // "refs/tags/v1.2.3"
+	CheckoutSHA       string               `json:"checkout_sha"`          // After
+	Message           string               `json:"message"`               // The release notes.
+	UserName          string               `json:"user_name"`             // "Release Notifier"
+	Project           WebHookGitLabProject `json:"project"`               // The project the tag was pushed to.
+	Commits           []interface{}        `json:"commits"`               // []
+	TotalCommitsCount int                  `json:"total_commits_count"`   // 0 (CompareSummary.CommitCount with service.compare)
+	Compare           *CompareSummary      `json:"compare,omitempty"`     // What changed since the previous version (service.compare).
+	RollbackTo        string               `json:"rollback_to,omitempty"` // The version that's the latest again when the release was retracted.
+}
+
+// WebHookGitLabProject is the project of a GitLab event.
+type WebHookGitLabProject struct {
+	Name              string `json:"name"`                // "repo"
+	PathWithNamespace string `json:"path_with_namespace"` // "owner/repo"
+	WebURL            string `json:"web_url"`             // "https://gitlab.com/owner/repo"
+	Homepage          string `json:"homepage"`            // "https://gitlab.com/owner/repo"
+}
+
+// newWebHookGitLab returns the GitLab 'Tag Push Hook' payload for the release of event.
+func newWebHookGitLab(event *ReleaseEvent) WebHookGitLab {
+	serviceURL := event.ServiceURL
+	fullName := event.Repository
+	sha := randHexLower(40)
+	// A deleted tag is pushed from its SHA to 0's.
+	before, after := strings.Repeat("0", 40), sha
+	if event.RollbackTo != "" {
+		before, after = sha, strings.Repeat("0", 40)
+	}
+	totalCommits := 0
+	if event.Compare != nil {
+		totalCommits = event.Compare.CommitCount
+	}
+
+	return WebHookGitLab{
+		ObjectKind:  "tag_push",
+		EventName:   "tag_push",
+		Before:      before,
+		After:       after,
+		Ref:         fmt.Sprintf("refs/tags/%s", event.getTag()),
+		CheckoutSHA: after,
+		Message:     event.Notes,
+		UserName:    "Release Notifier",
+		Project: WebHookGitLabProject{
+			Name:              getRepositoryName(fullName),
+			PathWithNamespace: fullName,
+			WebURL:            serviceURL,
+			Homepage:          serviceURL,
+		},
+		Commits:           []interface{}{},
+		TotalCommitsCount: totalCommits,
+		Compare:           event.Compare,
+		RollbackTo:        event.RollbackTo,
+	}
+}
+
+// WebHookGitea is the WebHook payload to emulate a Gitea 'push' event of a tag.
+type WebHookGitea struct {
+	Ref        string                  `json:"ref"`                   // "refs/tags/v1.2.3"
+	Before     string                  `json:"before"`                // "0000000000000000000000000000000000000000"
+	After      string                  `json:"after"`                 // "randHexLower(40)"
+	CompareURL string                  `json:"compare_url"`           // "" (CompareSummary.URL with service.compare)
+	Commits    []interface{}           `json:"commits"`               // []
+	Repository WebHookGitHubRepository `json:"repository"`            // The repository the tag was pushed to.
+	Compare    *CompareSummary         `json:"compare,omitempty"`     // What changed since the previous version (service.compare).
+	RollbackTo string                  `json:"rollback_to,omitempty"` // The version that's the latest again when the release was retracted.
+}
+
+// newWebHookGitea returns the Gitea 'push' event payload of a tag for the release of event.
+func newWebHookGitea(event *ReleaseEvent) WebHookGitea {
+	fullName := event.Repository
+	compareURL := ""
+	if event.Compare != nil {
+		compareURL = event.Compare.URL
+	}
+	// A deleted tag is pushed from its SHA to 0's.
+	before, after := strings.Repeat("0", 40), randHexLower(40)
+	if event.RollbackTo != "" {
+		before, after = after, before
+	}
+
+	return WebHookGitea{
+		Ref:        fmt.Sprintf("refs/tags/%s", event.getTag()),
+		Before:     before,
+		After:      after,
+		CompareURL: compareURL,
+		Commits:    []interface{}{},
+		Repository: WebHookGitHubRepository{
+			Name:     getRepositoryName(fullName),
+			FullName: fullName,
+			HTMLURL:  event.ServiceURL,
+		},
+		Compare:    event.Compare,
+		RollbackTo: event.RollbackTo,
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewWebHookGitHub(t *testing.T) {
+	svc := Service{
+		ID:   "Release-Notifier",
+		Type: "github",
+		URL:  "https://api.github.com/repos/JosephKav/Release-Notifier/releases/latest",
+	}
+	svc.status = newStatus()
+	svc.status.setVersion("1.2.3", GitHubRelease{
+		TagName: "v1.2.3",
+		Body:    "Bug fixes",
+		HTMLURL: "https://github.com/JosephKav/Release-Notifier/releases/tag/v1.2.3",
+	})
+
+	got := newWebHookGitHub(newReleaseEvent("Monitor", &svc))
+	if got.Action != "published" {
+		t.Fatalf(`action = %s, want match for published`, got.Action)
+	}
+	if got.Release.TagName != "v1.2.3" {
+		t.Fatalf(`release.tag_name = %s, want match for v1.2.3`, got.Release.TagName)
+	}
+	// Name should fall back to the version when the release has no name.
+	if got.Release.Name != "1.2.3" {
+		t.Fatalf(`release.name = %s, want match for 1.2.3`, got.Release.Name)
+	}
+	if got.Release.Body != "Bug fixes" {
+		t.Fatalf(`release.body = %s, want match for Bug fixes`, got.Release.Body)
+	}
+	if got.Repository.FullName != "JosephKav/Release-Notifier" {
+		t.Fatalf(`repository.full_name = %s, want match for JosephKav/Release-Notifier`, got.Repository.FullName)
+	}
+	if got.Repository.HTMLURL != "https://github.com/JosephKav/Release-Notifier" {
+		t.Fatalf(`repository.html_url = %s, want match for https://github.com/JosephKav/Release-Notifier`, got.Repository.HTMLURL)
+	}
+}
+
+func TestWebHookRollback(t *testing.T) {
+	event := &ReleaseEvent{ServiceID: "owner/repo", Version: "1.2.3", RollbackTo: "1.2.2"}
+
+	if got := newWebHookGitHub(event); got.Action != "deleted" || got.RollbackTo != "1.2.2" {
+		t.Fatalf(`github = %+v, want match for a deleted release rolling back to 1.2.2`, got)
+	}
+	zeros := strings.Repeat("0", 40)
+	if got := newWebHookGitLab(event); got.After != zeros || got.Before == zeros || got.RollbackTo != "1.2.2" {
+		t.Fatalf(`gitlab = %+v, want match for a deleted tag rolling back to 1.2.2`, got)
+	}
+	if got := newWebHookGitea(event); got.After != zeros || got.Before == zeros || got.RollbackTo != "1.2.2" {
+		t.Fatalf(`gitea = %+v, want match for a deleted tag rolling back to 1.2.2`, got)
+	}
+}
+
+func TestWebHookSetHeaders(t *testing.T) {
+	payload := []byte(`{"ref":"refs/tags/v1.2.3"}`)
+
+	// GitLab sends the secret as a token.
+	webhook := WebHook{Type: "gitlab", Secret: "SECRET"}
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com", nil)
+	webhook.setHeaders(req, "DELIVERY_ID", payload)
+	if got := req.Header.Get("X-Gitlab-Token"); got != "SECRET" {
+		t.Fatalf(`gitlab X-Gitlab-Token = %s, want match for SECRET`, got)
+	}
+	if got := req.Header.Get("X-Gitlab-Event"); got != "Tag Push Hook" {
+		t.Fatalf(`gitlab X-Gitlab-Event = %s, want match for Tag Push Hook`, got)
+	}
+
+	// Gitea signs the payload with HMAC-SHA256.
+	webhook = WebHook{Type: "gitea", Secret: "SECRET"}
+	req, _ = http.NewRequest(http.MethodPost, "https://example.com", nil)
+	webhook.setHeaders(req, "DELIVERY_ID", payload)
+	hash := hmac.New(sha256.New, []byte("SECRET"))
+	hash.Write(payload)
+	want := hex.EncodeToString(hash.Sum(nil))
+	if got := req.Header.Get("X-Gitea-Signature"); got != want {
+		t.Fatalf(`gitea X-Gitea-Signature = %s, want match for %s`, got, want)
+	}
+}
+
+func TestWebHookSetStandardHeaders(t *testing.T) {
+	var (
+		payload    = []byte(`{"test": 2432232314}`)
+		deliveryID = "msg_p5jXN8AQM9LWM0D4loKWxJek"
+		timestamp  = time.Unix(1614265330, 0)
+		want       = "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="
+	)
+
+	webhook := WebHook{Signing: "standard", Secret: "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"}
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com", nil)
+	webhook.setStandardHeaders(req, deliveryID, timestamp, payload)
+	if got := req.Header.Get("webhook-signature"); got != want {
+		t.Fatalf(`webhook-signature = %s, want match for %s`, got, want)
+	}
+	if got := req.Header.Get("webhook-timestamp"); got != "1614265330" {
+		t.Fatalf(`webhook-timestamp = %s, want match for 1614265330`, got)
+	}
+
+	// Rotated secrets should each add a signature.
+	webhook.Secrets = []string{"whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"}
+	webhook.setStandardHeaders(req, deliveryID, timestamp, payload)
+	if got := req.Header.Get("webhook-signature"); got != want+" "+want {
+		t.Fatalf(`webhook-signature = %s, want match for %s`, got, want+" "+want)
+	}
+}
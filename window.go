@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// windowFormat is how the time a delivery is scheduled for is shown in notifications.
+const windowFormat = "Mon 2006-01-02 15:04 MST"
+
+// windowOpens returns t if it's within the Window of the NotifyOptions (or there isn't one),
+// otherwise when the Window next opens after t.
+func (o *NotifyOptions) windowOpens(t time.Time) time.Time {
+	if o.Window == nil || notifierTypes[o.Kind].messenger {
+		return t
+	}
+	open := o.Window.nextOpen(t, o.getLocation())
+	if open.IsZero() {
+		// Check again tomorrow in case it's a blackout of over a year.
+		return t.AddDate(0, 0, 1)
+	}
+	return open
+}
+
+// getLocation returns the timezone of the Window.
+func (o *NotifyOptions) getLocation() *time.Location {
+	if o.location == nil {
+		return time.Local
+	}
+	return o.location
+}
+
+// setWindow will make delivery of the release of event to notifier wait for the deploy window of notifier to open,
+// telling the messengers of this Monitor when it's scheduled for if it has to wait.
+func (m *Monitor) setWindow(delivery *Delivery, notifier Notifier, event *ReleaseEvent, defaults Defaults) {
+	options := notifier.getOptions()
+	open := options.windowOpens(delivery.Scheduled)
+	if !open.After(delivery.Scheduled) {
+		return
+	}
+	delivery.Scheduled = open
+
+	what := notifierTypes[options.Kind].name
+	if event.RollbackTo != "" {
+		what = "rollback " + what
+	}
+	scheduled := open.In(options.getLocation()).Format(windowFormat)
+	msg := fmt.Sprintf("%s (%s), The %s of %s to %s is outside its deploy window, so is scheduled for %s", event.ServiceID, m.ID, what, event.Version, notifier.getTarget(), scheduled)
+	jLog.Info(msg, true)
+
+	title := fmt.Sprintf("%s %s %s scheduled", event.ServiceID, event.Version, what)
+	message := fmt.Sprintf("The %s of %s %s to %s is outside its deploy window, so it's scheduled for %s.", what, event.ServiceID, event.Version, notifier.getTarget(), scheduled)
+	m.alert(event, title, message, defaults, notifier)
+}
+
+// window will wait until the deploy window of notifier is open to send delivery (saving when it's scheduled for).
+func (o *Outbox) window(notifier Notifier, delivery *Delivery, kind notifierType) {
+	options := notifier.getOptions()
+	for {
+		now := time.Now()
+		open := options.windowOpens(now)
+		if !open.After(now) {
+			return
+		}
+
+		msg := fmt.Sprintf("%s (%s), Waiting until %s for the deploy window to open before sending the %s", delivery.ServiceID, delivery.MonitorID, open.In(options.getLocation()).Format(windowFormat), kind.name)
+		jLog.Verbose(msg, true)
+		delivery.Scheduled = open
+		o.mutex.Lock()
+		o.save(outboxPending, delivery)
+		o.mutex.Unlock()
+		time.Sleep(time.Until(open))
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowOpens(t *testing.T) {
+	window := &Calendar{Days: "tue-thu", Hours: "09:00-16:00", ExcludeDates: []string{"2022-06-08"}}
+	if err := window.parse(); err != nil {
+		t.Fatalf(`parse() = %v, want match for <nil>`, err)
+	}
+	options := NotifyOptions{Kind: "webhook", Window: window, location: time.UTC}
+	tests := []struct {
+		at   string
+		want string
+	}{
+		{"2022-06-07T10:00:00Z", "2022-06-07T10:00:00Z"}, // Tuesday morning - open.
+		{"2022-06-07T16:00:00Z", "2022-06-09T09:00:00Z"}, // Tuesday evening -> Thursday (Wednesday is a blackout).
+		{"2022-06-10T12:00:00Z", "2022-06-14T09:00:00Z"}, // Friday -> Tuesday.
+	}
+	for _, test := range tests {
+		at, _ := time.Parse(time.RFC3339, test.at)
+		if got := options.windowOpens(at).Format(time.RFC3339); got != test.want {
+			t.Fatalf(`windowOpens(%s) = %s, want match for %s`, test.at, got, test.want)
+		}
+	}
+
+	// Messengers (and notifiers without a window) aren't held back.
+	at, _ := time.Parse(time.RFC3339, "2022-06-10T12:00:00Z")
+	for _, options := range []NotifyOptions{{Kind: "slack", Window: window}, {Kind: "webhook"}} {
+		if got := options.windowOpens(at); !got.Equal(at) {
+			t.Fatalf(`windowOpens(%s) of %s = %s, want match for %s`, at, options.Kind, got, at)
+		}
+	}
+}
+
+func TestSetWindow(t *testing.T) {
+	window := &Calendar{Days: "mon-sun", Hours: "00:00-24:00", ExcludeDates: []string{time.Now().Format("2006-01-02")}}
+	if err := window.parse(); err != nil {
+		t.Fatalf(`parse() = %v, want match for <nil>`, err)
+	}
+	monitor := Monitor{ID: "Monitor"}
+	event := &ReleaseEvent{ServiceID: "Service", Version: "1.2.3"}
+
+	// Today is a blackout, so wait for tomorrow.
+	delivery := newDelivery("webhook", "Monitor", 0, "", "Service", []byte(`{}`), "0s")
+	monitor.setWindow(delivery, &WebHook{NotifyOptions: NotifyOptions{Kind: "webhook", Window: window}}, event, Defaults{})
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	want := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, time.Local)
+	if !delivery.Scheduled.Equal(want) {
+		t.Fatalf(`delivery.Scheduled = %s, want match for %s`, delivery.Scheduled, want)
+	}
+
+	// Open.
+	window.ExcludeDates = nil
+	window.parse()
+	delivery = newDelivery("webhook", "Monitor", 0, "", "Service", []byte(`{}`), "0s")
+	scheduled := delivery.Scheduled
+	monitor.setWindow(delivery, &WebHook{NotifyOptions: NotifyOptions{Kind: "webhook", Window: window}}, event, Defaults{})
+	if !delivery.Scheduled.Equal(scheduled) {
+		t.Fatalf(`delivery.Scheduled = %s, want match for %s`, delivery.Scheduled, scheduled)
+	}
+}